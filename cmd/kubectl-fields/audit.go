@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"github.com/ahmetb/kubectl-fields/internal/audit"
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"go.yaml.in/yaml/v3"
+)
+
+// annotateAuditLog reads audit events (typically JSON lines, or an EventList)
+// from path ("-" for stdin) and writes the annotated object of each mutating
+// event, headed by the event's details. Other documents and read-only events
+// are skipped.
+func (p *pipeline) annotateAuditLog(path string) error {
+	r, closeInput, err := openInput(path)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	dec, err := p.decoder(r)
+	if err != nil {
+		return err
+	}
+	for {
+		tok, err := dec.Next()
+		if err == io.EOF {
+			return nil
+		}
+		var docErr *parser.DocumentError
+		if errors.As(err, &docErr) {
+			if err := p.report(docErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if tok.Type != parser.DocumentToken && tok.Type != parser.ListItemToken {
+			continue
+		}
+
+		p.w.startDocument()
+		for _, item := range parser.UnwrapListKind(tok.Node) {
+			if err := p.annotateAuditEvent(tok, item); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// annotateAuditEvent writes the annotated object of a single audit event read
+// as part of tok, if it is a mutating event with a response object.
+func (p *pipeline) annotateAuditEvent(tok parser.Token, doc *yaml.Node) error {
+	ev, ok, err := audit.FromDocument(doc)
+	if err != nil {
+		return p.reportDocument(tok, err)
+	}
+	if !ok || !ev.Mutating() || ev.Object == nil || !p.selects(ev.Object) {
+		return nil
+	}
+
+	// Malformed entries are reported by processDocument below.
+	entries, _ := managed.ExtractManagedFields(ev.Object.Content[0])
+	opts := p.opts
+	opts.Request, _ = audit.MatchEntry(entries, ev)
+	ev.Object.HeadComment = ev.Header(opts.Request)
+
+	if _, err := p.processDocument(ev.Object, opts); err != nil {
+		if err := p.reportDocument(tok, err); err != nil {
+			return err
+		}
+	}
+	if p.query != nil {
+		return nil
+	}
+	return p.w.write(ev.Object)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ahmetb/kubectl-fields/internal/annotate"
+	"github.com/ahmetb/kubectl-fields/internal/input"
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"go.yaml.in/yaml/v3"
+)
+
+// applyManifest is the manifest given with --apply, to be applied by
+// fieldManager.
+type applyManifest struct {
+	fieldManager string
+	docs         []*yaml.Node      // documents of the manifest
+	objects      []*manifestObject // objects in docs, including List items
+}
+
+// manifestObject is an object of an apply manifest, with whether a live
+// object was found for it and the conflicts of applying it.
+type manifestObject struct {
+	root      *yaml.Node
+	found     bool
+	conflicts []annotate.Conflict
+}
+
+// find returns the manifest object with the kind, name and namespace of
+// live, marking it found, or nil. A manifest object without a namespace
+// matches a live object in any namespace.
+func (m *applyManifest) find(live *yaml.Node) *manifestObject {
+	kind := parser.MapScalar(live, "kind")
+	metadata := parser.MapValue(live, "metadata")
+	name := parser.MapScalar(metadata, "name")
+	namespace := parser.MapScalar(metadata, "namespace")
+	for _, obj := range m.objects {
+		objMetadata := parser.MapValue(obj.root, "metadata")
+		if parser.MapScalar(obj.root, "kind") != kind || parser.MapScalar(objMetadata, "name") != name {
+			continue
+		}
+		if ns := parser.MapScalar(objMetadata, "namespace"); ns == "" || ns == namespace {
+			obj.found = true
+			return obj
+		}
+	}
+	return nil
+}
+
+// readManifest reads the manifest at path ("-" for stdin) to be applied by
+// fieldManager, and returns its format.
+func readManifest(path, fieldManager string) (*applyManifest, parser.Format, error) {
+	r, closeInput, err := openInput(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer closeInput()
+	dec, err := parser.NewDecoder(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	m := &applyManifest{fieldManager: fieldManager}
+	for {
+		tok, err := dec.Next()
+		if err == io.EOF {
+			return m, dec.Format(), nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if tok.Type != parser.DocumentToken && tok.Type != parser.ListItemToken {
+			continue
+		}
+		m.docs = append(m.docs, tok.Node)
+		for _, item := range parser.UnwrapListKind(tok.Node) {
+			if len(item.Content) > 0 && item.Content[0].Kind == yaml.MappingNode {
+				m.objects = append(m.objects, &manifestObject{root: item.Content[0]})
+			}
+		}
+	}
+}
+
+// warnUnmatchedManifest warns about the objects of the --apply manifest
+// that are not in the input.
+func (p *pipeline) warnUnmatchedManifest() {
+	if p.apply == nil {
+		return
+	}
+	for _, obj := range p.apply.objects {
+		if !obj.found {
+			warn(fmt.Sprintf("no live object for %s in the input; applying creates it", describeObject(obj.root)))
+		}
+	}
+}
+
+// predictConflicts predicts the server-side apply conflicts of applying the
+// manifest at manifestPath as fieldManager to the live objects read from
+// liveInputs ("-" for stdin). It lists the conflicts on stderr, writes the
+// manifest with the conflicting fields annotated with their owners and
+// returns their number.
+func (p *pipeline) predictConflicts(manifestPath, fieldManager string, liveInputs []string) (int, error) {
+	m, format, err := readManifest(manifestPath, fieldManager)
+	if err != nil {
+		return 0, fmt.Errorf("reading --apply %s: %w", manifestPath, err)
+	}
+	p.apply = m
+	p.w = p.newWriter(format)
+
+	total := 0
+	for _, path := range liveInputs {
+		label := path
+		if path == input.Stdin {
+			label = "stdin"
+		}
+		n, err := p.conflictsWithInput(path)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", label, err)
+		}
+	}
+
+	p.warnUnmatchedManifest()
+	// Conflicts are injected in manifest order, the order they are written.
+	opts := p.opts
+	var formatErr error
+	opts.FormatError = func(err error) {
+		if formatErr == nil {
+			formatErr = err
+		}
+	}
+	for _, obj := range m.objects {
+		annotate.InjectConflicts(obj.conflicts, opts)
+	}
+	if formatErr != nil {
+		if err := p.report(fmt.Errorf("--format: %w", formatErr)); err != nil {
+			return total, err
+		}
+	}
+	for _, doc := range m.docs {
+		if err := p.w.write(doc); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// readPruneManifest reads the manifest at path to be applied by
+// fieldManager, so the fields of the live objects that applying it would
+// delete are marked as they are annotated.
+func (p *pipeline) readPruneManifest(path, fieldManager string) error {
+	m, _, err := readManifest(path, fieldManager)
+	if err != nil {
+		return fmt.Errorf("reading --apply %s: %w", path, err)
+	}
+	p.apply = m
+	return nil
+}
+
+// predictPrunes returns the paths of the fields of the live object root that
+// applying the --apply manifest would delete, and lists them on stderr.
+func (p *pipeline) predictPrunes(root *yaml.Node, entries []managed.ManagedFieldsEntry, s *schema.Schema) []string {
+	obj := p.apply.find(root)
+	if obj == nil {
+		return nil
+	}
+	pruned := annotate.FindPrunes(obj.root, root, entries, p.apply.fieldManager, s)
+	if len(pruned) > 0 {
+		var sb strings.Builder
+		if p.source != "" {
+			sb.WriteString(p.source + ": ")
+		}
+		fmt.Fprintf(&sb, "fields of %s deleted when applied as %q:\n", describeObject(root), p.apply.fieldManager)
+		for _, path := range pruned {
+			sb.WriteString("  " + path + "\n")
+		}
+		fmt.Fprint(os.Stderr, sb.String())
+	}
+	return pruned
+}
+
+// conflictsWithInput finds the conflicts of the --apply manifest objects
+// with the live objects read from path, recording them on the objects, and
+// returns their number.
+func (p *pipeline) conflictsWithInput(path string) (int, error) {
+	r, closeInput, err := openInput(path)
+	if err != nil {
+		return 0, err
+	}
+	defer closeInput()
+	dec, err := p.decoder(r)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for {
+		tok, err := dec.Next()
+		if err == io.EOF {
+			return total, nil
+		}
+		var docErr *parser.DocumentError
+		if errors.As(err, &docErr) {
+			if err := p.report(docErr); err != nil {
+				return total, err
+			}
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+		switch tok.Type {
+		case parser.ListStartToken:
+			p.enterList(tok.Node)
+			continue
+		case parser.ListEndToken:
+			p.enterList(nil)
+			continue
+		case parser.DocumentToken:
+			if len(tok.Node.Content) > 0 {
+				p.enterList(tok.Node.Content[0])
+			}
+		}
+
+		for _, item := range parser.UnwrapListKind(tok.Node) {
+			if len(item.Content) == 0 || item.Content[0].Kind != yaml.MappingNode {
+				continue
+			}
+			live := item.Content[0]
+			obj := p.apply.find(live)
+			if obj == nil {
+				continue
+			}
+
+			entries, err := managed.ExtractManagedFields(live)
+			if err != nil {
+				if err := p.reportDocument(tok, fmt.Errorf("extracting managedFields: %w", err)); err != nil {
+					return total, err
+				}
+			}
+			if len(entries) > 0 {
+				p.foundManagedFields = true
+			}
+			conflicts := annotate.FindConflicts(obj.root, live, entries, p.apply.fieldManager, p.objectSchema(live))
+			if len(conflicts) > 0 {
+				obj.conflicts = append(obj.conflicts, conflicts...)
+				reportConflicts(obj.root, p.apply.fieldManager, conflicts)
+			}
+			total += len(conflicts)
+		}
+	}
+}
+
+// reportConflicts lists the conflicts of a manifest object on stderr, with
+// the managers owning each field and, for scalars, its live value.
+func reportConflicts(root *yaml.Node, fieldManager string, conflicts []annotate.Conflict) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "conflicts applying %s as %q:\n", describeObject(root), fieldManager)
+	for _, c := range conflicts {
+		owners := make([]string, len(c.Owners))
+		for i, o := range c.Owners {
+			owners[i] = fmt.Sprintf("%s (%s)", o.Manager, strings.ToLower(o.Operation))
+		}
+		sb.WriteString("  " + c.Path + ": " + strings.Join(owners, " + "))
+		if c.Live.Kind == yaml.ScalarNode {
+			sb.WriteString(", live value " + c.Live.Value)
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprint(os.Stderr, sb.String())
+}
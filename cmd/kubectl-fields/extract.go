@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ahmetb/kubectl-fields/internal/annotate"
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"github.com/ahmetb/kubectl-fields/internal/selector"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+// extractSpec selects the managedFields entries whose fields the extract
+// command writes.
+type extractSpec struct {
+	manager     string
+	subresource string
+	found       bool // whether any object had an entry of manager
+}
+
+// newExtractCommand creates the extract subcommand.
+func newExtractCommand() *cobra.Command {
+	var outputFlagVar outputFlag = "auto"
+
+	cmd := &cobra.Command{
+		Use:   "extract --manager NAME [FILE|DIR|GLOB...]",
+		Short: "Write the fields owned by a manager as an apply-ready manifest",
+		Long: `kubectl fields extract reads Kubernetes objects with managedFields (from stdin,
+or from the files, directories and glob patterns given as arguments) and writes
+each object reduced to the fields the given manager owns, like client-go's
+ExtractApplyConfiguration but offline. The result keeps the identifying fields
+(apiVersion, kind, metadata.name and metadata.namespace) and the key fields of
+the list items it contains, so it can be applied with
+"kubectl apply --server-side --field-manager NAME", for example to see what a
+manager applied or to move the ownership of its fields to another tool.
+
+The fields of all of the manager's entries (Apply and Update) for the main
+resource are extracted; use --subresource to extract those it wrote through a
+subresource such as status instead.
+
+Usage:
+  kubectl get deploy nginx -o yaml --show-managed-fields | kubectl fields extract --manager helm
+  kubectl fields extract --manager kube-controller-manager --subresource status -R ./dump/`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manager, _ := cmd.Flags().GetString("manager")
+			subresource, _ := cmd.Flags().GetString("subresource")
+			keepList, _ := cmd.Flags().GetBool("keep-list")
+			strict, _ := cmd.Flags().GetBool("strict")
+			recursive, _ := cmd.Flags().GetBool("recursive")
+			namespaces, _ := cmd.Flags().GetStringSlice("namespace")
+			kinds, _ := cmd.Flags().GetStringSlice("kind")
+			names, _ := cmd.Flags().GetStringSlice("name")
+			openAPIFiles, _ := cmd.Flags().GetStringArray("openapi")
+			crdPaths, _ := cmd.Flags().GetStringArray("crd")
+
+			sel := selector.Selector{Namespaces: namespaces, Kinds: kinds, Names: names}
+			if err := sel.Validate(); err != nil {
+				return err
+			}
+			schemas, err := loadSchemas(openAPIFiles, crdPaths)
+			if err != nil {
+				return err
+			}
+
+			spec := &extractSpec{manager: manager, subresource: subresource}
+			p := &pipeline{
+				keepList: keepList,
+				strict:   strict,
+				selector: sel,
+				schemas:  schemas,
+				extract:  spec,
+				newWriter: func(input parser.Format) *documentWriter {
+					return newDocumentWriter(os.Stdout, outputFlagVar.resolve(input), false, nil)
+				},
+			}
+			if err := p.annotateArgs(args, recursive); err != nil {
+				return err
+			}
+
+			switch {
+			case !sel.Empty() && !p.matched:
+				warn("no objects matched the --namespace, --kind and --name filters")
+			case !p.foundManagedFields:
+				warn("no managedFields found. Did you use --show-managed-fields?")
+			case !spec.found:
+				warn(fmt.Sprintf("no managedFields entries of manager %q found", manager))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().String("manager", "", "Field manager whose fields to extract (required)")
+	cmd.Flags().String("subresource", "", "Extract the fields the manager wrote through this subresource (such as status)")
+	cmd.Flags().Bool("keep-list", false, "Keep List envelopes instead of writing each item as a separate document")
+	cmd.Flags().BoolP("recursive", "R", false, "Process directories given as arguments recursively")
+	cmd.Flags().StringSliceP("namespace", "n", nil, "Only write objects in namespaces matching these glob patterns")
+	cmd.Flags().StringSlice("kind", nil, "Only write objects of kinds matching these glob patterns (case-insensitive)")
+	cmd.Flags().StringSlice("name", nil, "Only write objects with names matching these glob patterns")
+	cmd.Flags().StringArray("openapi", nil, "Load OpenAPI v3 schemas in addition to the built-in ones; may be repeated")
+	cmd.Flags().StringArray("crd", nil, "Load the schemas of custom resources from CustomResourceDefinition files or directories; may be repeated")
+	cmd.Flags().Bool("strict", false, "Fail on the first malformed document or managedFields entry instead of reporting it and continuing")
+	cmd.Flags().VarP(&outputFlagVar, "output", "o", "Output format: auto (same as input), yaml, json")
+	cmd.MarkFlagRequired("manager")
+	return cmd
+}
+
+// extractDocument reduces the object root to the fields owned by the
+// entries of the extracted manager. It reports false, leaving root
+// unchanged, when the manager has no entries for the object, which is then
+// not written.
+func (p *pipeline) extractDocument(root *yaml.Node, entries []managed.ManagedFieldsEntry) bool {
+	var owned []managed.ManagedFieldsEntry
+	for _, entry := range entries {
+		if entry.Manager == p.extract.manager && entry.Subresource == p.extract.subresource {
+			owned = append(owned, entry)
+		}
+	}
+	if len(owned) == 0 {
+		return false
+	}
+	p.extract.found = true
+	*root = *annotate.Extract(root, owned, p.objectSchema(root))
+	return true
+}
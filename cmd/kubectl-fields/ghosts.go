@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ahmetb/kubectl-fields/internal/annotate"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"go.yaml.in/yaml/v3"
+)
+
+// ghostsFlag is a pflag.Value for the --ghosts flag accepting none|inline|stderr|both.
+type ghostsFlag string
+
+func (f *ghostsFlag) String() string { return string(*f) }
+func (f *ghostsFlag) Set(val string) error {
+	switch val {
+	case "none", "inline", "stderr", "both":
+		*f = ghostsFlag(val)
+		return nil
+	default:
+		return fmt.Errorf("must be one of: none, inline, stderr, both")
+	}
+}
+func (f *ghostsFlag) Type() string { return "string" }
+
+// inline reports whether ghosts are written as comments in the output.
+func (f ghostsFlag) inline() bool { return f == "inline" || f == "both" }
+
+// stderr reports whether ghosts are summarized on stderr.
+func (f ghostsFlag) stderr() bool { return f == "stderr" || f == "both" }
+
+// reportGhosts prints the stale ownership claims of an object on stderr,
+// grouped by manager in the order the managers first appear.
+func (p *pipeline) reportGhosts(root *yaml.Node, ghosts []annotate.Ghost) {
+	var managers []string
+	byManager := make(map[string][]string)
+	for _, g := range ghosts {
+		manager := g.Owner.Manager
+		if g.Owner.Subresource != "" {
+			manager += " /" + g.Owner.Subresource
+		}
+		if _, ok := byManager[manager]; !ok {
+			managers = append(managers, manager)
+		}
+		byManager[manager] = append(byManager[manager], g.String())
+	}
+
+	var sb strings.Builder
+	if p.source != "" {
+		sb.WriteString(p.source + ": ")
+	}
+	sb.WriteString("stale managedFields in " + describeObject(root) + ":\n")
+	for _, manager := range managers {
+		sb.WriteString("  " + manager + ":\n")
+		for _, ghost := range byManager[manager] {
+			sb.WriteString("    " + ghost + "\n")
+		}
+	}
+	fmt.Fprint(os.Stderr, sb.String())
+}
+
+// describeObject names a resource as "Kind namespace/name" (or "Kind name"
+// for cluster-scoped objects).
+func describeObject(root *yaml.Node) string {
+	metadata := parser.MapValue(root, "metadata")
+	name := parser.MapScalar(metadata, "name")
+	if ns := parser.MapScalar(metadata, "namespace"); ns != "" {
+		name = ns + "/" + name
+	}
+	if kind := parser.MapScalar(root, "kind"); kind != "" {
+		return kind + " " + name
+	}
+	return name
+}
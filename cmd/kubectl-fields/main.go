@@ -1,17 +1,20 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/ahmetb/kubectl-fields/internal/annotate"
+	"github.com/ahmetb/kubectl-fields/internal/input"
 	"github.com/ahmetb/kubectl-fields/internal/managed"
 	"github.com/ahmetb/kubectl-fields/internal/output"
 	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"github.com/ahmetb/kubectl-fields/internal/selector"
+	"github.com/ahmetb/kubectl-fields/internal/timeutil"
+	"github.com/ahmetb/kubectl-fields/internal/watch"
 	"github.com/spf13/cobra"
-	"go.yaml.in/yaml/v3"
 	"golang.org/x/term"
 )
 
@@ -45,102 +48,307 @@ func (f *mtimeFlag) Set(val string) error {
 }
 func (f *mtimeFlag) Type() string { return "string" }
 
+// ownerOrderFlag is a pflag.Value for the --owner-order flag accepting time|apply.
+type ownerOrderFlag string
+
+func (f *ownerOrderFlag) String() string { return string(*f) }
+func (f *ownerOrderFlag) Set(val string) error {
+	switch val {
+	case "time", "apply":
+		*f = ownerOrderFlag(val)
+		return nil
+	default:
+		return fmt.Errorf("must be one of: time, apply")
+	}
+}
+func (f *ownerOrderFlag) Type() string { return "string" }
+
+// operationFlag is a pflag.Value for the --operation flag accepting Apply|Update
+// (case-insensitive).
+type operationFlag string
+
+func (f *operationFlag) String() string { return string(*f) }
+func (f *operationFlag) Set(val string) error {
+	switch strings.ToLower(val) {
+	case "apply":
+		*f = "Apply"
+		return nil
+	case "update":
+		*f = "Update"
+		return nil
+	default:
+		return fmt.Errorf("must be one of: Apply, Update")
+	}
+}
+func (f *operationFlag) Type() string { return "string" }
+
+// outputFlag is a pflag.Value for the --output flag accepting auto|yaml|json.
+type outputFlag string
+
+func (f *outputFlag) String() string { return string(*f) }
+func (f *outputFlag) Set(val string) error {
+	switch val {
+	case "auto", "yaml", "json":
+		*f = outputFlag(val)
+		return nil
+	default:
+		return fmt.Errorf("must be one of: auto, yaml, json")
+	}
+}
+func (f *outputFlag) Type() string { return "string" }
+
+// resolve returns the output format, with auto matching the input format.
+func (f outputFlag) resolve(input parser.Format) parser.Format {
+	if f == "auto" {
+		return input
+	}
+	return parser.Format(f)
+}
+
 func main() {
 	var colorFlagVar colorFlag = "auto"
 	var mtimeFlagVar mtimeFlag = "relative"
+	var outputFlagVar outputFlag = "auto"
+	var ownerOrderFlagVar ownerOrderFlag = "time"
+	var ghostsFlagVar ghostsFlag = "none"
+	var pathOutputFlagVar pathOutputFlag = "table"
+	var operationFlagVar operationFlag
 
 	rootCmd := &cobra.Command{
-		Use:   "kubectl fields",
+		Use:   "fields [FILE|DIR|GLOB...]",
 		Short: "Annotate Kubernetes YAML with field ownership information",
-		Long: `kubectl fields reads Kubernetes resource YAML from stdin, annotates each
+		Long: `kubectl fields reads Kubernetes resource YAML or JSON from stdin (or from
+the files, directories and glob patterns given as arguments), annotates each
 managed field with its owner (manager name and timestamp), and writes the
-annotated YAML to stdout.
+annotated result to stdout.
 
 Usage:
   kubectl get deploy nginx -o yaml --show-managed-fields | kubectl fields
   kubectl get deploy nginx -o yaml --show-managed-fields | kubectl fields --color always
-  kubectl get deploy nginx -o yaml --show-managed-fields | kubectl fields --mtime hide
   kubectl get deploy -o yaml --show-managed-fields | kubectl fields --above
-  kubectl get deploy nginx -o yaml --show-managed-fields | kubectl fields --show-operation
+  kubectl get deploy nginx -o json --show-managed-fields | kubectl fields -o yaml
+  kubectl get deploy nginx -w -o yaml --show-managed-fields | kubectl fields --watch
+  kubectl fields -R ./dump/ -n prod --kind Deployment
+  kubectl fields --audit-log /var/log/kubernetes/audit.log
+  kubectl fields ./dump.yaml --apply nginx.yaml --field-manager argocd
+  kubectl fields ./dump.yaml --path 'spec.template.spec.containers[name=nginx].image'
 
 The tool processes managedFields metadata to show who owns each field
 and when it was last updated, making field ownership visible without
 reading raw managedFields JSON.`,
-		SilenceUsage:  true,
-		SilenceErrors: true,
+		Annotations:       map[string]string{cobra.CommandDisplayNameAnnotation: "kubectl fields"},
+		Args:              cobra.ArbitraryArgs,
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+		SilenceUsage:      true,
+		SilenceErrors:     true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			aboveMode, _ := cmd.Flags().GetBool("above")
 			showOperation, _ := cmd.Flags().GetBool("show-operation")
+			unowned, _ := cmd.Flags().GetBool("unowned")
+			inherit, _ := cmd.Flags().GetBool("inherit")
+			keepList, _ := cmd.Flags().GetBool("keep-list")
+			watchMode, _ := cmd.Flags().GetBool("watch")
+			auditLog, _ := cmd.Flags().GetString("audit-log")
+			strict, _ := cmd.Flags().GetBool("strict")
+			recursive, _ := cmd.Flags().GetBool("recursive")
+			namespaces, _ := cmd.Flags().GetStringSlice("namespace")
+			kinds, _ := cmd.Flags().GetStringSlice("kind")
+			names, _ := cmd.Flags().GetStringSlice("name")
+			semantics, _ := cmd.Flags().GetBool("semantics")
+			openAPIFiles, _ := cmd.Flags().GetStringArray("openapi")
+			crdPaths, _ := cmd.Flags().GetStringArray("crd")
+			applyFile, _ := cmd.Flags().GetString("apply")
+			fieldManager, _ := cmd.Flags().GetString("field-manager")
+			prune, _ := cmd.Flags().GetBool("prune")
+			includeManagers, _ := cmd.Flags().GetStringArray("manager")
+			excludeManagers, _ := cmd.Flags().GetStringArray("exclude-manager")
+			paths, _ := cmd.Flags().GetStringArray("path")
+			split, _ := cmd.Flags().GetBool("split-subresources")
+			since, _ := cmd.Flags().GetString("since")
+			before, _ := cmd.Flags().GetString("before")
+			highlightRecent, _ := cmd.Flags().GetString("highlight-recent")
+			format, _ := cmd.Flags().GetString("format")
+			if applyFile != "" && fieldManager == "" {
+				return fmt.Errorf("--apply requires --field-manager")
+			}
+			if prune && applyFile == "" {
+				return fmt.Errorf("--prune requires --apply")
+			}
+			if len(paths) > 0 && applyFile != "" {
+				return fmt.Errorf("--path cannot be used with --apply")
+			}
+			if split {
+				for _, flag := range []string{"subresource", "keep-list", "watch", "audit-log", "apply", "path"} {
+					if cmd.Flags().Changed(flag) {
+						return fmt.Errorf("--split-subresources cannot be used with --%s", flag)
+					}
+				}
+			}
 
-			// Resolve color mode: auto detects TTY, always/never override.
-			colorEnabled := output.ResolveColor(string(colorFlagVar), term.IsTerminal(int(os.Stdout.Fd())))
-			colorMgr := output.NewColorManager()
+			sel := selector.Selector{Namespaces: namespaces, Kinds: kinds, Names: names}
+			if err := sel.Validate(); err != nil {
+				return err
+			}
 
-			docs, err := parser.ParseDocuments(os.Stdin)
+			managers, err := managed.NewManagerFilter(includeManagers, excludeManagers)
 			if err != nil {
 				return err
 			}
+			now := time.Now()
+			filter := managed.EntryFilter{Managers: managers, Operation: string(operationFlagVar)}
+			if cmd.Flags().Changed("subresource") {
+				subresource, _ := cmd.Flags().GetString("subresource")
+				filter.Subresource = &subresource
+			}
+			if since != "" {
+				if filter.After, err = timeutil.ParseTime(since, now); err != nil {
+					return fmt.Errorf("--since: %w", err)
+				}
+			}
+			if before != "" {
+				if filter.Before, err = timeutil.ParseTime(before, now); err != nil {
+					return fmt.Errorf("--before: %w", err)
+				}
+			}
+			var recent time.Duration
+			if highlightRecent != "" {
+				if recent, err = timeutil.ParseDuration(highlightRecent); err != nil {
+					return fmt.Errorf("--highlight-recent: %w", err)
+				}
+			}
 
-			// Unwrap any List kind documents into individual items.
-			var allDocs []*yaml.Node
-			for _, doc := range docs {
-				allDocs = append(allDocs, parser.UnwrapListKind(doc)...)
+			schemas, err := loadSchemas(openAPIFiles, crdPaths)
+			if err != nil {
+				return err
 			}
 
-			// Extract managedFields, annotate fields, then strip managedFields.
-			foundManagedFields := false
-			for _, doc := range allDocs {
-				if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
-					continue
-				}
-				root := doc.Content[0]
+			// Resolve color mode: auto detects TTY, always/never override.
+			colorEnabled := output.ResolveColor(string(colorFlagVar), term.IsTerminal(int(os.Stdout.Fd())))
+			colorMgr := output.NewColorManager()
 
-				entries, err := managed.ExtractManagedFields(root)
-				if err != nil {
-					return fmt.Errorf("extracting managedFields: %w", err)
+			opts := annotate.Options{
+				Above:         aboveMode,
+				Now:           now,
+				Mtime:         annotate.MtimeMode(mtimeFlagVar),
+				ShowOperation: showOperation,
+				OwnerOrder:    annotate.OwnerOrder(ownerOrderFlagVar),
+				Unowned:       unowned,
+				Inherit:       inherit,
+				Semantics:     semantics,
+			}
+			if recent > 0 {
+				opts.Recent = now.Add(-recent)
+			}
+			if format != "" {
+				if opts.Format, err = annotate.ParseTemplate(format); err != nil {
+					return fmt.Errorf("--format: %w", err)
 				}
-				if len(entries) > 0 {
-					foundManagedFields = true
+				if colorEnabled {
+					// The manager of a custom annotation cannot be
+					// parsed back from its text.
+					opts.Rendered = colorMgr.Register
 				}
+			}
 
-				// Annotate owned fields with ownership comments.
-				if len(entries) > 0 {
-					annotate.Annotate(root, entries, annotate.Options{
-						Above:         aboveMode,
-						Now:           time.Now(),
-						Mtime:         annotate.MtimeMode(mtimeFlagVar),
-						ShowOperation: showOperation,
-					})
+			p := &pipeline{
+				opts:     opts,
+				keepList: keepList,
+				strict:   strict,
+				selector: sel,
+				ghosts:   ghostsFlagVar,
+				schemas:  schemas,
+				filter:   filter,
+				split:    split,
+				recent:   recent,
+				newWriter: func(input parser.Format) *documentWriter {
+					return newDocumentWriter(os.Stdout, outputFlagVar.resolve(input), colorEnabled, colorMgr)
+				},
+			}
+			if watchMode {
+				p.tracker = watch.NewTracker()
+			}
+			if len(paths) > 0 {
+				if p.query, err = newPathQuery(os.Stdout, paths, pathOutputFlagVar, opts.Mtime); err != nil {
+					return err
 				}
-
-				// Strip managedFields from the YAML tree.
-				managed.StripManagedFields(root)
+				p.query.stream = watchMode
 			}
 
-			if !foundManagedFields {
-				msg := "Warning: no managedFields found. Did you use --show-managed-fields?"
-				if term.IsTerminal(int(os.Stderr.Fd())) {
-					msg = "\x1b[33m" + msg + "\x1b[0m" // orange/yellow
+			conflicts := 0
+			switch {
+			case applyFile != "" && prune:
+				if err = p.readPruneManifest(applyFile, fieldManager); err == nil {
+					err = p.annotateArgs(args, recursive)
+				}
+				p.warnUnmatchedManifest()
+			case applyFile != "":
+				live := []string{input.Stdin}
+				if len(args) > 0 {
+					if live, err = input.Files(args, recursive); err != nil {
+						return err
+					}
 				}
-				fmt.Fprintln(os.Stderr, msg)
+				conflicts, err = p.predictConflicts(applyFile, fieldManager, live)
+			case auditLog != "":
+				err = p.annotateAuditLog(auditLog)
+			default:
+				err = p.annotateArgs(args, recursive)
 			}
-
-			// Encode YAML to buffer, then post-process (align + colorize).
-			var buf bytes.Buffer
-			if err := parser.EncodeDocuments(&buf, allDocs); err != nil {
+			if p.query != nil {
+				if flushErr := p.query.flush(); err == nil {
+					err = flushErr
+				}
+			}
+			if err != nil {
 				return err
 			}
 
-			result := output.FormatOutput(buf.String(), colorEnabled, colorMgr)
-			_, err = fmt.Fprint(os.Stdout, result)
-			return err
+			if msg := p.emptyResultWarning(applyFile == "" || prune); msg != "" {
+				warn(msg)
+			}
+			if conflicts > 0 {
+				return fmt.Errorf("%d field(s) would conflict when applied as %q", conflicts, fieldManager)
+			}
+			return nil
 		},
 	}
 
 	rootCmd.Flags().Bool("above", false, "Place annotations on the line above each field instead of inline")
 	rootCmd.Flags().Bool("show-operation", false, "Include operation type (apply, update) in annotations")
+	rootCmd.Flags().Bool("inherit", false, "Annotate fields inside atomically owned containers (such as a label selector) with the container's owner, marked [inherited]")
+	rootCmd.Flags().Bool("unowned", false, "Mark fields no manager owns and count owned and unowned fields per document")
+	rootCmd.Flags().Bool("semantics", false, "Append the server-side apply semantics of lists and maps from their schema, such as [list-type: atomic]")
+	rootCmd.Flags().StringArray("openapi", nil, "Load OpenAPI v3 schemas (kubectl get --raw /openapi/v3/...) in addition to the built-in ones; may be repeated")
+	rootCmd.Flags().StringArray("crd", nil, "Load the schemas of custom resources from CustomResourceDefinition files or directories; may be repeated")
+	rootCmd.Flags().Bool("keep-list", false, "Keep List envelopes and annotate items in place instead of writing each item as a separate document")
+	rootCmd.Flags().BoolP("watch", "w", false, "Annotate an endless stream (kubectl get -w) and mark fields whose ownership changed since the previous revision")
+	rootCmd.Flags().String("apply", "", "Predict the server-side apply conflicts of this manifest with the live objects read from the input, and write the manifest with the conflicting fields annotated")
+	rootCmd.Flags().String("field-manager", "", "Field manager name to predict --apply conflicts for")
+	rootCmd.Flags().Bool("prune", false, "With --apply, write the live objects annotated and mark the fields the apply would delete instead of predicting conflicts")
+	rootCmd.Flags().StringArray("manager", nil, "Only annotate the fields of managers matching this glob or /regexp/; may be repeated")
+	rootCmd.Flags().StringArray("exclude-manager", nil, "Do not annotate the fields of managers matching this glob or /regexp/; may be repeated")
+	rootCmd.Flags().StringArray("path", nil, "Write the owners of the field at this path (such as spec.containers[name=app].image) instead of the annotated objects; may be repeated")
+	rootCmd.Flags().Var(&pathOutputFlagVar, "path-output", "Output of --path: table, yaml")
+	rootCmd.Flags().String("subresource", "", "Only annotate the fields written through this subresource (such as status or scale; \"\" for the main resource)")
+	rootCmd.Flags().Var(&operationFlagVar, "operation", "Only annotate the fields written by this operation: Apply, Update")
+	rootCmd.Flags().Bool("split-subresources", false, "Write each object once per subresource its fields were written through (main resource, status, ...), annotated with the entries of that subresource")
+	rootCmd.Flags().String("since", "", "Only annotate the fields of entries updated within this duration (such as 2h or 3d) or since this RFC3339 time")
+	rootCmd.Flags().String("before", "", "Only annotate the fields of entries updated before this RFC3339 time or longer ago than this duration")
+	rootCmd.Flags().String("highlight-recent", "", "Mark the fields updated within this duration (such as 1h) with [recent], inverted in color output")
+	rootCmd.Flags().String("format", "", "Go template for annotations, such as '[{{.Manager}}]', with the fields .Manager, .Operation, .Op, .Subresource, .APIVersion, .Time, .Age, .RFC3339 and .CoOwners; overrides --mtime and --show-operation")
+	rootCmd.Flags().String("audit-log", "", "Read a Kubernetes audit log (\"-\" for stdin) and annotate the object of each mutating event")
+	rootCmd.Flags().BoolP("recursive", "R", false, "Process directories given as arguments recursively")
+	rootCmd.Flags().StringSliceP("namespace", "n", nil, "Only write objects in namespaces matching these glob patterns")
+	rootCmd.Flags().StringSlice("kind", nil, "Only write objects of kinds matching these glob patterns (case-insensitive)")
+	rootCmd.Flags().StringSlice("name", nil, "Only write objects with names matching these glob patterns")
+	rootCmd.Flags().Bool("strict", false, "Fail on the first malformed document or managedFields entry instead of reporting it and continuing")
 	rootCmd.Flags().Var(&colorFlagVar, "color", "Color output: auto, always, never")
 	rootCmd.Flags().Var(&mtimeFlagVar, "mtime", "Timestamp display: relative, absolute, hide")
+	rootCmd.Flags().Var(&ownerOrderFlagVar, "owner-order", "Order of the managers co-owning a field: time (most recent first), apply (Apply before Update)")
+	rootCmd.Flags().Var(&ghostsFlagVar, "ghosts", "Report managedFields paths missing from the object: none, inline, stderr, both")
+	rootCmd.Flags().VarP(&outputFlagVar, "output", "o", "Output format: auto (same as input), yaml, json")
+
+	rootCmd.AddCommand(newExtractCommand())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/annotate"
+	"github.com/ahmetb/kubectl-fields/internal/input"
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"github.com/ahmetb/kubectl-fields/internal/selector"
+	"github.com/ahmetb/kubectl-fields/internal/watch"
+	"go.yaml.in/yaml/v3"
+	"golang.org/x/term"
+)
+
+// watchIdleTimeout is how long --watch waits for more input before printing a
+// YAML object that has not been followed by a "---" separator yet.
+const watchIdleTimeout = 100 * time.Millisecond
+
+// pipeline annotates decoded documents and writes them out. It holds the
+// settings shared by all input modes.
+type pipeline struct {
+	// newWriter creates the output writer once the format of the first
+	// input is known.
+	newWriter func(input parser.Format) *documentWriter
+	w         *documentWriter
+
+	opts     annotate.Options
+	keepList bool           // write streamed List envelopes around their items
+	tracker  *watch.Tracker // non-nil in watch mode
+	strict   bool           // fail on the first problem instead of reporting it
+	selector selector.Selector
+	split    bool                // write each object once per subresource of its entries
+	recent   time.Duration       // --highlight-recent, relative to the arrival of each revision in watch mode
+	ghosts   ghostsFlag          // where to report stale ownership claims
+	schemas  *schema.Registry    // schemas of the kinds with known semantics
+	apply    *applyManifest      // manifest given with --apply, if any
+	filter   managed.EntryFilter // managedFields entries whose fields are annotated
+	query    *pathQuery          // fields given with --path, if any
+	extract  *extractSpec        // manager whose fields are extracted, if any
+
+	source             string // label of the input being read, if any
+	foundManagedFields bool   // whether any document had managedFields entries
+	keptEntries        bool   // whether any entries passed the entry filter
+	matched            bool   // whether any object matched the selector
+	itemKind           string // kind of the items of the current typed list
+	itemAPIVersion     string // apiVersion of the current typed list
+	skip               bool   // whether the document last processed is left out of the output
+}
+
+// annotateArgs annotates the files, directories and glob patterns given as
+// arguments, or stdin when there are none.
+func (p *pipeline) annotateArgs(args []string, recursive bool) error {
+	if len(args) == 0 {
+		return p.annotateInput(input.Stdin, "")
+	}
+	files, err := input.Files(args, recursive)
+	if err != nil {
+		return err
+	}
+	return p.annotateFiles(files)
+}
+
+// annotateFiles annotates each file in turn ("-" for stdin), labelling their
+// documents with the file they came from.
+func (p *pipeline) annotateFiles(files []string) error {
+	for _, path := range files {
+		label := path
+		if path == input.Stdin {
+			label = "stdin"
+		}
+		if err := p.annotateInput(path, label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// annotateInput annotates the documents of one input ("-" for stdin). A
+// non-empty label names the input in document headers and in diagnostics.
+// Tar archives are read file by file, each labelled "archive:name".
+func (p *pipeline) annotateInput(path, label string) error {
+	if input.IsArchive(path) {
+		return input.WalkArchive(path, func(name string, r io.Reader) error {
+			return p.annotateReader(r, path+":"+name)
+		})
+	}
+
+	r, closeInput, err := openInput(path)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+	return p.annotateReader(r, label)
+}
+
+// annotateReader annotates the documents read from r, labelled as in
+// annotateInput.
+func (p *pipeline) annotateReader(r io.Reader, label string) error {
+	p.source = label
+	dec, err := p.decoder(r)
+	if err == nil {
+		err = p.annotateStream(dec)
+	}
+	if err != nil && label != "" {
+		return fmt.Errorf("%s: %w", label, err)
+	}
+	return err
+}
+
+// openInput opens path for reading, with "-" standing for stdin.
+func openInput(path string) (io.Reader, func(), error) {
+	if path == input.Stdin {
+		return os.Stdin, func() {}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { f.Close() }, nil
+}
+
+// decoder creates a Decoder for r, creating the output writer for the
+// format of the first input.
+func (p *pipeline) decoder(r io.Reader) (*parser.Decoder, error) {
+	dec, err := parser.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	if p.tracker != nil {
+		dec.SetIdleTimeout(watchIdleTimeout)
+	}
+	if p.w == nil {
+		p.w = p.newWriter(dec.Format())
+	}
+	return dec, nil
+}
+
+// annotateStream decodes, annotates and writes one document at a time. With
+// keepList, streamed List envelopes are written around their items.
+func (p *pipeline) annotateStream(dec *parser.Decoder) error {
+	for {
+		tok, err := dec.Next()
+		if err == io.EOF {
+			return nil
+		}
+		var docErr *parser.DocumentError
+		if errors.As(err, &docErr) {
+			if err := p.report(docErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		switch tok.Type {
+		case parser.ListStartToken:
+			p.enterList(tok.Node)
+			if p.keepList && p.query == nil {
+				p.label(tok.Node)
+				err = p.w.beginList(tok.Node)
+			}
+		case parser.ListEndToken:
+			p.enterList(nil)
+			if p.keepList && p.query == nil {
+				err = p.w.endList(tok.Node)
+			}
+		default:
+			if !p.selectDocument(tok) {
+				continue
+			}
+			p.w.startDocument()
+			// Unwrap List documents the decoder could not stream item
+			// by item. Items share nodes with the List, so annotating
+			// them also annotates the List in place.
+			items := parser.UnwrapListKind(tok.Node)
+			if p.split {
+				err = p.writeSubresourcePasses(tok, items)
+				break
+			}
+			kept := make(map[*yaml.Node]bool, len(items))
+			for _, item := range items {
+				p.skip = false
+				if err := p.processItem(item); err != nil {
+					if err := p.reportDocument(tok, err); err != nil {
+						return err
+					}
+				}
+				if len(item.Content) > 0 {
+					kept[item.Content[0]] = !p.skip
+				}
+			}
+			if p.query == nil && !p.dropSkipped(tok, kept) {
+				continue
+			}
+
+			switch {
+			case p.query != nil:
+				// The query wrote the results for the items.
+			case p.keepList && tok.Type == parser.ListItemToken:
+				err = p.w.writeItem(tok.Node)
+			case p.keepList:
+				p.label(tok.Node)
+				err = p.w.write(tok.Node)
+			default:
+				for _, item := range parser.UnwrapListKind(tok.Node) {
+					p.label(item)
+					if err = p.w.write(item); err != nil {
+						break
+					}
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// dropSkipped removes the items of a List read as a whole that were
+// skipped, as recorded in kept by root node, and reports whether anything is
+// left to write of the document (or List item) read as tok. A List is
+// written even when all of its items were skipped.
+func (p *pipeline) dropSkipped(tok parser.Token, kept map[*yaml.Node]bool) bool {
+	if parser.FilterListItems(tok.Node, func(item *yaml.Node) bool { return kept[item.Content[0]] }) {
+		return true
+	}
+	return len(tok.Node.Content) == 0 || kept[tok.Node.Content[0]]
+}
+
+// writeSubresourcePasses annotates and writes each item once per
+// subresource its managedFields entries were written through, starting with
+// the main resource, using the entries of that subresource only. Each pass
+// is a copy of the item headed by a comment naming the subresource.
+func (p *pipeline) writeSubresourcePasses(tok parser.Token, items []*yaml.Node) error {
+	for _, item := range items {
+		for _, sub := range subresources(item) {
+			doc := parser.CloneNode(item, true)
+			if sub == "" {
+				doc.HeadComment = "Fields written through the main resource"
+			} else {
+				doc.HeadComment = "Fields written through the " + sub + " subresource"
+			}
+
+			filter := p.filter
+			p.filter.Subresource = &sub
+			err := p.processItem(doc)
+			p.filter = filter
+			if err != nil {
+				if err := p.reportDocument(tok, err); err != nil {
+					return err
+				}
+			}
+			p.label(doc)
+			if err := p.w.write(doc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// subresources returns "" (the main resource) followed by the subresources
+// of the managedFields entries of a document, in the order they first
+// appear.
+func subresources(doc *yaml.Node) []string {
+	subs := []string{""}
+	if _, object, ok := watch.UnwrapEvent(doc); ok {
+		doc = object
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return subs
+	}
+	entries, _ := managed.ExtractManagedFields(doc.Content[0])
+	for _, entry := range entries {
+		if !slices.Contains(subs, entry.Subresource) {
+			subs = append(subs, entry.Subresource)
+		}
+	}
+	return subs
+}
+
+// enterList records the kind and apiVersion of the items of the typed list
+// whose root (or streamed List header) is list, or clears them when list is
+// nil or not a typed list. kubectl omits both from the items of typed lists.
+func (p *pipeline) enterList(list *yaml.Node) {
+	p.itemKind = parser.ItemKind(list)
+	p.itemAPIVersion = ""
+	if p.itemKind != "" {
+		p.itemAPIVersion = parser.MapScalar(list, "apiVersion")
+	}
+}
+
+// objectSchema returns the schema of the object root, taking the kind and
+// apiVersion of the current typed list for an item that has no kind.
+func (p *pipeline) objectSchema(root *yaml.Node) *schema.Schema {
+	apiVersion, kind := parser.MapScalar(root, "apiVersion"), parser.MapScalar(root, "kind")
+	if kind == "" {
+		kind = p.itemKind
+		if apiVersion == "" {
+			apiVersion = p.itemAPIVersion
+		}
+	}
+	return p.schemas.ForKind(apiVersion, kind)
+}
+
+// selectDocument applies the selector to the document (or List item) read
+// as tok and reports whether it should be written. Items of a List read as a
+// whole that do not match are removed from it. The kind of the items of a
+// typed list read as a whole is recorded until the next document.
+func (p *pipeline) selectDocument(tok parser.Token) bool {
+	if tok.Type == parser.DocumentToken && len(tok.Node.Content) > 0 {
+		p.enterList(tok.Node.Content[0])
+		if parser.FilterListItems(tok.Node, p.selects) {
+			return true
+		}
+	}
+	return p.selects(tok.Node)
+}
+
+// selects reports whether a document (or the object of a WatchEvent) matches
+// the selector.
+func (p *pipeline) selects(doc *yaml.Node) bool {
+	if p.selector.Empty() {
+		p.matched = true
+		return true
+	}
+	if _, object, ok := watch.UnwrapEvent(doc); ok {
+		doc = object
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || !p.selector.Matches(doc.Content[0], p.itemKind) {
+		return false
+	}
+	p.matched = true
+	return true
+}
+
+// label adds a "Source:" head comment naming the current input to a document
+// (or List envelope) about to be written.
+func (p *pipeline) label(node *yaml.Node) {
+	if p.source == "" {
+		return
+	}
+	comment := "Source: " + p.source
+	if node.HeadComment != "" {
+		comment += "\n" + node.HeadComment
+	}
+	node.HeadComment = comment
+}
+
+// report handles a problem with a single document: in strict mode it is
+// returned as an error, otherwise it is printed as a warning, naming the
+// current input, and processing continues.
+func (p *pipeline) report(err error) error {
+	if p.strict {
+		return err
+	}
+	msg := err.Error()
+	if p.source != "" {
+		msg = p.source + ": " + msg
+	}
+	warn(msg)
+	return nil
+}
+
+// reportDocument reports a problem with the document (or List item) read as
+// tok.
+func (p *pipeline) reportDocument(tok parser.Token, err error) error {
+	return p.report(&parser.DocumentError{Index: tok.Index, Line: tok.Line, Err: err})
+}
+
+// emptyResultWarning returns the warning to print when the filters or the
+// input left nothing to annotate, or "". filtered reports whether the entry
+// filter applied; conflicts are predicted without it.
+func (p *pipeline) emptyResultWarning(filtered bool) string {
+	switch {
+	case !p.selector.Empty() && !p.matched:
+		return "no objects matched the --namespace, --kind and --name filters"
+	case !p.foundManagedFields:
+		return "no managedFields found. Did you use --show-managed-fields?"
+	case filtered && !p.filter.Empty() && !p.keptEntries:
+		return "no managedFields entries matched the --manager, --exclude-manager, --operation, --subresource, --since and --before filters"
+	}
+	return ""
+}
+
+// processItem annotates a document, unwrapping WatchEvent envelopes. In watch
+// mode, timestamps are relative to the arrival of the document and fields
+// whose ownership changed since the previous revision of the same object are
+// marked.
+func (p *pipeline) processItem(doc *yaml.Node) error {
+	eventType, object, ok := watch.UnwrapEvent(doc)
+	if !ok {
+		object = doc
+	}
+	if p.tracker == nil {
+		_, err := p.processDocument(object, p.opts)
+		return err
+	}
+
+	uid := ""
+	if len(object.Content) > 0 {
+		uid = watch.UID(object.Content[0])
+	}
+	opts := p.opts
+	opts.Now = time.Now()
+	if p.recent > 0 {
+		opts.Recent = opts.Now.Add(-p.recent)
+	}
+	opts.Previous = p.tracker.Previous(uid)
+
+	owned, err := p.processDocument(object, opts)
+	p.tracker.Record(uid, eventType, owned)
+	return err
+}
+
+// processDocument extracts managedFields from a document, annotates the owned
+// fields, then strips managedFields. It returns the ownership of the annotated
+// fields. Malformed managedFields entries are left out of the annotations and
+// reported in the returned error, as are list items an entry addresses by key
+// that cannot be found.
+func (p *pipeline) processDocument(doc *yaml.Node, opts annotate.Options) (annotate.Ownership, error) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	var errs []error
+	if opts.Format != nil {
+		// A template that fails for one field usually fails for many;
+		// the first error is reported for the object.
+		failed := false
+		opts.FormatError = func(err error) {
+			if !failed {
+				failed = true
+				errs = append(errs, fmt.Errorf("--format: %w", err))
+			}
+		}
+	}
+	entries, err := managed.ExtractManagedFields(root)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("extracting managedFields: %w", err))
+	}
+
+	if len(entries) > 0 {
+		p.foundManagedFields = true
+	}
+	if p.extract != nil {
+		p.skip = !p.extractDocument(root, entries)
+		return nil, errors.Join(errs...)
+	}
+
+	if len(entries) > 0 {
+		opts.Schema = p.objectSchema(root)
+		if p.apply != nil {
+			opts.Pruned = p.predictPrunes(root, entries, opts.Schema)
+		}
+	}
+
+	// Annotate owned fields with ownership comments. Prunes are predicted
+	// from every entry above; only the annotations are limited to the
+	// entries selected by the manager, operation and subresource filters.
+	var owned annotate.Ownership
+	entries = p.filter.Filter(entries)
+	if len(entries) > 0 {
+		p.keptEntries = true
+	}
+	switch {
+	case p.query != nil:
+		// The owners of the --path fields are written instead.
+		results := annotate.Query(root, entries, p.query.paths, opts)
+		if err := p.query.write(p.source, root, results, opts.Now); err != nil {
+			return nil, err
+		}
+	case len(entries) > 0:
+		if p.ghosts == "none" {
+			// Otherwise unresolved k: elements are reported as ghosts.
+			opts.Warn = func(msg string) { errs = append(errs, errors.New(msg)) }
+		}
+		var leaves annotate.LeafCounts
+		if opts.Unowned {
+			opts.Leaves = &leaves
+		}
+		owned = annotate.Annotate(root, entries, opts)
+		if opts.Unowned {
+			summary := fmt.Sprintf("Leaves: %d owned, %d unowned", leaves.Owned, leaves.Unowned)
+			if doc.HeadComment != "" {
+				summary = doc.HeadComment + "\n" + summary
+			}
+			doc.HeadComment = summary
+		}
+		if p.ghosts.inline() || p.ghosts.stderr() {
+			p.processGhosts(root, entries, opts.Schema)
+		}
+	}
+
+	// Strip managedFields from the YAML tree.
+	managed.StripManagedFields(root)
+	return owned, errors.Join(errs...)
+}
+
+// processGhosts reports the stale ownership claims of entries as comments,
+// on stderr or both, as configured. s is the schema of the object, if known.
+func (p *pipeline) processGhosts(root *yaml.Node, entries []managed.ManagedFieldsEntry, s *schema.Schema) {
+	ghosts := annotate.FindGhosts(root, entries, s)
+	if len(ghosts) == 0 {
+		return
+	}
+	if p.ghosts.inline() {
+		annotate.InjectGhosts(ghosts)
+	}
+	if p.ghosts.stderr() {
+		p.reportGhosts(root, ghosts)
+	}
+}
+
+// warn prints a warning to stderr, highlighted when stderr is a terminal.
+func warn(msg string) {
+	msg = "Warning: " + msg
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		msg = "\x1b[33m" + msg + "\x1b[0m" // orange/yellow
+	}
+	fmt.Fprintln(os.Stderr, msg)
+}
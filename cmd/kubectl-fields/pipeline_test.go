@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/annotate"
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/output"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+// newTestPipeline returns a pipeline writing uncolored output to out, with
+// timestamps hidden.
+func newTestPipeline(out *bytes.Buffer) *pipeline {
+	return &pipeline{
+		opts:   annotate.Options{Now: testNow, Mtime: annotate.MtimeHide},
+		ghosts: "none",
+		newWriter: func(input parser.Format) *documentWriter {
+			return newDocumentWriter(out, input, false, output.NewColorManager())
+		},
+	}
+}
+
+// configMap returns a ConfigMap whose data.key is owned by manager.
+func configMap(name, manager string) string {
+	return `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ` + name + `
+  managedFields:
+  - manager: ` + manager + `
+    operation: Update
+    apiVersion: v1
+    time: "2025-01-15T11:00:00Z"
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:data:
+        f:key: {}
+data:
+  key: value
+`
+}
+
+// indent indents every line of s by n spaces.
+func indent(s string, n int) string {
+	lines := strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Repeat(" ", n) + line
+	}
+	return strings.Join(lines, "") + "\n"
+}
+
+// list returns a kubectl List (items printed before kind) of the items.
+func list(items ...string) string {
+	var sb strings.Builder
+	sb.WriteString("apiVersion: v1\nitems:\n")
+	for _, item := range items {
+		item = indent(item, 2)
+		sb.WriteString("-" + item[1:])
+	}
+	sb.WriteString("kind: List\nmetadata:\n  resourceVersion: \"\"\n")
+	return sb.String()
+}
+
+func TestPipeline_StreamedList(t *testing.T) {
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	require.NoError(t, p.annotateReader(strings.NewReader(list(configMap("one", "kubectl"), configMap("two", "helm"))), ""))
+
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: one
+data:
+  key: value  # kubectl
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: two
+data:
+  key: value  # helm
+`, out.String())
+	assert.True(t, p.foundManagedFields)
+}
+
+func TestPipeline_KeepList(t *testing.T) {
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	p.keepList = true
+	require.NoError(t, p.annotateReader(strings.NewReader(list(configMap("one", "kubectl"), configMap("two", "helm"))), ""))
+
+	assert.Equal(t, `apiVersion: v1
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: one
+  data:
+    key: value  # kubectl
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: two
+  data:
+    key: value  # helm
+kind: List
+metadata:
+  resourceVersion: ""
+`, out.String())
+}
+
+func TestPipeline_FlushesEachDocument(t *testing.T) {
+	// Each document is written before the next one is read.
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	var written []string
+	r := &callbackReader{
+		data: []byte(configMap("one", "kubectl") + "---\n" + configMap("two", "helm")),
+		onRead: func() {
+			written = append(written, out.String())
+		},
+	}
+	require.NoError(t, p.annotateReader(r, ""))
+	assert.Contains(t, written, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: one\ndata:\n  key: value  # kubectl\n")
+}
+
+// callbackReader returns data one line per Read, calling onRead before each.
+type callbackReader struct {
+	data   []byte
+	onRead func()
+}
+
+func (r *callbackReader) Read(p []byte) (int, error) {
+	r.onRead()
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := bytes.IndexByte(r.data, '\n') + 1
+	if n == 0 || n > len(p) {
+		n = min(len(r.data), len(p))
+	}
+	copy(p, r.data[:n])
+	r.data = r.data[n:]
+	return n, nil
+}
+
+const malformedInput = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: one
+---
+data: [unterminated
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: three
+`
+
+func TestPipeline_RecoversFromDocumentError(t *testing.T) {
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	require.NoError(t, p.annotateReader(strings.NewReader(malformedInput), ""))
+
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: one
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: three
+`, out.String())
+}
+
+func TestPipeline_StrictFailsOnDocumentError(t *testing.T) {
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	p.strict = true
+	err := p.annotateReader(strings.NewReader(malformedInput), "in.yaml")
+
+	var docErr *parser.DocumentError
+	require.ErrorAs(t, err, &docErr)
+	assert.Equal(t, 2, docErr.Index)
+	assert.ErrorContains(t, err, "in.yaml: document 2 ")
+	assert.Equal(t, "# Source: in.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: one\n", out.String())
+}
+
+func TestPipeline_SourceHeaderSameWithKeepList(t *testing.T) {
+	// The header directly precedes the document and the List alike.
+	for _, keepList := range []bool{false, true} {
+		for _, input := range []string{configMap("one", "kubectl"), list(configMap("one", "kubectl"))} {
+			var out bytes.Buffer
+			p := newTestPipeline(&out)
+			p.keepList = keepList
+			require.NoError(t, p.annotateReader(strings.NewReader(input), "in.yaml"))
+			assert.True(t, strings.HasPrefix(out.String(), "# Source: in.yaml\napiVersion: v1\n"), out.String())
+		}
+	}
+}
+
+func TestPipeline_StrictFailsOnMalformedEntry(t *testing.T) {
+	input := strings.Replace(configMap("one", "kubectl"), `time: "2025-01-15T11:00:00Z"`, "time: yesterday", 1)
+
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	require.NoError(t, p.annotateReader(strings.NewReader(input), ""))
+	assert.Contains(t, out.String(), "name: one")
+
+	p = newTestPipeline(&out)
+	p.strict = true
+	assert.ErrorContains(t, p.annotateReader(strings.NewReader(input), ""), "document 1 (line 1)")
+}
+
+func TestPipeline_HeaderCommentsNotColored(t *testing.T) {
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	p.opts.Unowned = true
+	colorMgr := output.NewColorManager()
+	p.newWriter = func(input parser.Format) *documentWriter {
+		return newDocumentWriter(&out, input, true, colorMgr)
+	}
+	require.NoError(t, p.annotateReader(strings.NewReader(configMap("one", "kubectl")), "in.yaml"))
+
+	lines := strings.Split(out.String(), "\n")
+	assert.Equal(t, output.Dim+"# Source: in.yaml"+output.Reset, lines[0])
+	assert.Equal(t, output.Dim+"# Leaves: 1 owned, 3 unowned"+output.Reset, lines[1])
+	assert.Contains(t, out.String(), output.BrightPalette[0]+"# kubectl"+output.Reset)
+}
+
+func TestPipeline_WarnsWhenFiltersDropAllEntries(t *testing.T) {
+	managers, err := managed.NewManagerFilter(nil, []string{"*"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	p.filter = managed.EntryFilter{Managers: managers}
+	require.NoError(t, p.annotateReader(strings.NewReader(configMap("one", "kubectl")+"---\n"+configMap("two", "helm")), ""))
+
+	assert.NotContains(t, out.String(), "# kubectl")
+	assert.Equal(t, "no managedFields entries matched the --manager, --exclude-manager, --operation, --subresource, --since and --before filters", p.emptyResultWarning(true))
+	// Conflicts are predicted without the filter.
+	assert.Empty(t, p.emptyResultWarning(false))
+}
+
+func TestPipeline_WarnsWithoutManagedFields(t *testing.T) {
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	require.NoError(t, p.annotateReader(strings.NewReader(malformedInput), ""))
+	assert.Equal(t, "no managedFields found. Did you use --show-managed-fields?", p.emptyResultWarning(true))
+}
+
+const subresourceInput = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  managedFields:
+  - manager: kube-controller-manager
+    operation: Update
+    apiVersion: apps/v1
+    time: "2025-01-15T11:00:00Z"
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:status:
+        f:replicas: {}
+    subresource: status
+  - manager: kubectl
+    operation: Update
+    apiVersion: apps/v1
+    time: "2025-01-15T11:00:00Z"
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:spec:
+        f:replicas: {}
+spec:
+  replicas: 3
+status:
+  replicas: 3
+`
+
+func TestPipeline_SplitSubresources(t *testing.T) {
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	p.split = true
+	require.NoError(t, p.annotateReader(strings.NewReader(subresourceInput), ""))
+
+	// The main resource comes first, each pass annotated with the entries
+	// written through its subresource only.
+	assert.Equal(t, `# Fields written through the main resource
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3  # kubectl
+status:
+  replicas: 3
+---
+# Fields written through the status subresource
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+status:
+  replicas: 3  # kube-controller-manager /status
+`, out.String())
+	// The filter is restored after the passes.
+	assert.Nil(t, p.filter.Subresource)
+}
+
+func TestPipeline_FormatColorsFollowManagers(t *testing.T) {
+	// The same text rendered for different managers gets their colors.
+	tmpl, err := annotate.ParseTemplate("{{.Op}}")
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	colorMgr := output.NewColorManager()
+	p.opts.Format = tmpl
+	p.opts.Rendered = colorMgr.Register
+	p.newWriter = func(input parser.Format) *documentWriter {
+		return newDocumentWriter(&out, input, true, colorMgr)
+	}
+	require.NoError(t, p.annotateReader(strings.NewReader(subresourceInput), ""))
+
+	assert.Contains(t, out.String(), "spec:\n  replicas: 3  "+output.BrightPalette[0]+"# update"+output.Reset)
+	assert.Contains(t, out.String(), "status:\n  replicas: 3  "+output.BrightPalette[1]+"# update"+output.Reset)
+}
+
+func TestPipeline_FormatErrorReported(t *testing.T) {
+	// Valid for the samples, but fails for the fields owned by kubectl.
+	tmpl, err := annotate.ParseTemplate(`{{if eq .Manager "kubectl"}}{{.Time.Bad}}{{end}}{{.Op}}`)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	p.opts.Format = tmpl
+	p.strict = true
+	err = p.annotateReader(strings.NewReader(configMap("one", "kubectl")), "")
+	assert.ErrorContains(t, err, "document 1 (line 1): --format: template: format:1:")
+}
+
+func TestPipeline_CustomResourceItemsBeforeKind(t *testing.T) {
+	// A custom resource with a top-level items field printed before kind is
+	// written whole, not taken for a List.
+	input := `apiVersion: example.com/v1
+items:
+- name: a
+  value: 1
+kind: Widget
+metadata:
+  name: w
+  managedFields:
+  - manager: kubectl
+    operation: Apply
+    apiVersion: example.com/v1
+    time: "2025-01-15T11:00:00Z"
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:items: {}
+`
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	require.NoError(t, p.annotateReader(strings.NewReader(input), ""))
+
+	assert.Equal(t, `apiVersion: example.com/v1
+items:  # kubectl
+- name: a
+  value: 1
+kind: Widget
+metadata:
+  name: w
+`, out.String())
+	assert.True(t, p.foundManagedFields)
+}
+
+func TestPipeline_ExtractSkipsUnownedObjects(t *testing.T) {
+	// Objects the manager never wrote are left out rather than written as
+	// stubs of their identifying fields.
+	inputs := map[string]string{
+		"documents": configMap("one", "kubectl") + "---\n" + configMap("two", "helm"),
+		"list":      list(configMap("one", "kubectl"), configMap("two", "helm")),
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := newTestPipeline(&out)
+			p.extract = &extractSpec{manager: "helm"}
+			require.NoError(t, p.annotateReader(strings.NewReader(input), ""))
+
+			assert.Equal(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: two\ndata:\n  key: value\n", out.String())
+			assert.True(t, p.extract.found)
+		})
+	}
+}
+
+func TestPipeline_ExtractDropsUnownedItemsOfWholeList(t *testing.T) {
+	// A List of a group version is read whole; with --keep-list, the items
+	// the manager never wrote are removed from it.
+	input := strings.Replace(list(configMap("one", "kubectl"), configMap("two", "helm")), "apiVersion: v1\nitems:", "apiVersion: example.com/v1\nitems:", 1)
+
+	var out bytes.Buffer
+	p := newTestPipeline(&out)
+	p.keepList = true
+	p.extract = &extractSpec{manager: "helm"}
+	require.NoError(t, p.annotateReader(strings.NewReader(input), ""))
+
+	assert.NotContains(t, out.String(), "name: one")
+	assert.Contains(t, out.String(), "items:\n- apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: two\n")
+	assert.Contains(t, out.String(), "kind: List\n")
+}
+
+func TestPipeline_TypedListItemsUseListSchema(t *testing.T) {
+	// kubectl omits kind and apiVersion from the items of typed lists; their
+	// schema is that of the list's item kind.
+	pod := `metadata:
+  name: web
+  managedFields:
+  - manager: kubectl
+    operation: Apply
+    apiVersion: v1
+    time: "2025-01-15T11:00:00Z"
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:spec:
+        f:containers:
+          k:{"name":"app"}:
+            .: {}
+            f:name: {}
+spec:
+  containers:
+  - name: app
+`
+	// The flow-style List is read whole.
+	inputs := map[string]string{
+		"streamed": "kind: PodList\napiVersion: v1\nitems:\n-" + indent(pod, 2)[1:],
+		"whole":    "kind: PodList\napiVersion: v1\nitems: [" + `{"metadata":{"name":"web","managedFields":[{"manager":"kubectl","operation":"Apply","apiVersion":"v1","time":"2025-01-15T11:00:00Z","fieldsType":"FieldsV1","fieldsV1":{"f:spec":{"f:containers":{"k:{\"name\":\"app\"}":{".":{},"f:name":{}}}}}}]},"spec":{"containers":[{"name":"app"}]}}` + "]\n",
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := newTestPipeline(&out)
+			p.opts.Semantics = true
+			p.schemas = schema.Builtin()
+			require.NoError(t, p.annotateReader(strings.NewReader(input), ""))
+
+			assert.Contains(t, out.String(), "# [list-type: map keys=[name]]")
+		})
+	}
+}
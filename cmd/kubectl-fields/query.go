@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/annotate"
+	"github.com/ahmetb/kubectl-fields/internal/timeutil"
+	"go.yaml.in/yaml/v3"
+)
+
+// pathOutputFlag is a pflag.Value for the --path-output flag accepting table|yaml.
+type pathOutputFlag string
+
+func (f *pathOutputFlag) String() string { return string(*f) }
+func (f *pathOutputFlag) Set(val string) error {
+	switch val {
+	case "table", "yaml":
+		*f = pathOutputFlag(val)
+		return nil
+	default:
+		return fmt.Errorf("must be one of: table, yaml")
+	}
+}
+func (f *pathOutputFlag) Type() string { return "string" }
+
+// pathQuery writes the owners of the fields given with --path instead of the
+// annotated objects, as a table or as one YAML document per object.
+type pathQuery struct {
+	paths  []annotate.Path
+	format pathOutputFlag
+	mtime  annotate.MtimeMode
+	stream bool // flush the table after each object, as in watch mode
+
+	w      io.Writer
+	tw     *tabwriter.Writer
+	header bool // whether the table header was written
+}
+
+func newPathQuery(w io.Writer, exprs []string, format pathOutputFlag, mtime annotate.MtimeMode) (*pathQuery, error) {
+	q := &pathQuery{format: format, mtime: mtime, w: w}
+	for _, expr := range exprs {
+		p, err := annotate.ParsePath(expr)
+		if err != nil {
+			return nil, err
+		}
+		q.paths = append(q.paths, p)
+	}
+	q.tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	return q, nil
+}
+
+// queryField is the YAML output of the owners of one --path field.
+type queryField struct {
+	Path      string       `yaml:"path"`
+	Field     string       `yaml:"field,omitempty"`
+	Found     bool         `yaml:"found"`
+	Owners    []queryOwner `yaml:"owners,omitempty"`
+	Inherited bool         `yaml:"inherited,omitempty"`
+}
+
+// queryOwner is the YAML output of one owner of a --path field.
+type queryOwner struct {
+	Manager     string `yaml:"manager"`
+	Operation   string `yaml:"operation,omitempty"`
+	Subresource string `yaml:"subresource,omitempty"`
+	Time        string `yaml:"time,omitempty"`
+}
+
+// write writes the owners of the queried fields of the object root read from
+// source, with ages relative to now.
+func (q *pathQuery) write(source string, root *yaml.Node, results []annotate.FieldOwners, now time.Time) error {
+	object := describeObject(root)
+	if q.format == "yaml" {
+		return q.writeYAML(source, object, results)
+	}
+
+	if !q.header {
+		q.header = true
+		header := "OBJECT\tPATH\tMANAGER\tOPERATION"
+		if q.mtime != annotate.MtimeHide {
+			header += "\tUPDATED"
+		}
+		fmt.Fprintln(q.tw, header)
+	}
+	for _, r := range results {
+		switch {
+		case !r.Found:
+			fmt.Fprintf(q.tw, "%s\t%s\t<not found>\n", object, r.Path)
+		case len(r.Owners) == 0:
+			fmt.Fprintf(q.tw, "%s\t%s\t<none>\n", object, r.Path)
+		}
+		for _, o := range r.Owners {
+			manager := o.Manager
+			if o.Subresource != "" {
+				manager += " /" + o.Subresource
+			}
+			if r.Inherited {
+				manager += annotate.InheritedMark
+			}
+			row := []string{object, r.Path.String(), manager, strings.ToLower(o.Operation)}
+			switch q.mtime {
+			case annotate.MtimeAbsolute:
+				row = append(row, o.Time.UTC().Format(time.RFC3339))
+			case annotate.MtimeHide:
+			default:
+				row = append(row, timeutil.FormatRelativeTime(now, o.Time))
+			}
+			fmt.Fprintln(q.tw, strings.Join(row, "\t"))
+		}
+	}
+	if q.stream {
+		return q.flush()
+	}
+	return nil
+}
+
+// writeYAML writes the owners of the queried fields of an object as a YAML
+// document.
+func (q *pathQuery) writeYAML(source, object string, results []annotate.FieldOwners) error {
+	doc := struct {
+		Source string       `yaml:"source,omitempty"`
+		Object string       `yaml:"object"`
+		Fields []queryField `yaml:"fields"`
+	}{Source: source, Object: object}
+	for _, r := range results {
+		field := queryField{Path: r.Path.String(), Field: r.Field, Found: r.Found, Inherited: r.Inherited}
+		for _, o := range r.Owners {
+			field.Owners = append(field.Owners, queryOwner{
+				Manager:     o.Manager,
+				Operation:   o.Operation,
+				Subresource: o.Subresource,
+				Time:        o.Time.UTC().Format(time.RFC3339),
+			})
+		}
+		doc.Fields = append(doc.Fields, field)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := q.w.Write(buf.Bytes())
+	return err
+}
+
+// flush writes the buffered table rows.
+func (q *pathQuery) flush() error {
+	return q.tw.Flush()
+}
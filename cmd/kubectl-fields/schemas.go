@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ahmetb/kubectl-fields/internal/input"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+)
+
+// loadSchemas returns the built-in schemas extended with the OpenAPI v3
+// documents in openAPIFiles and the CustomResourceDefinitions in crdPaths
+// (files, directories or glob patterns). Files without CRDs are skipped, but
+// each of crdPaths must provide at least one schema.
+func loadSchemas(openAPIFiles, crdPaths []string) (*schema.Registry, error) {
+	schemas := schema.Builtin()
+	for _, path := range openAPIFiles {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = schemas.LoadOpenAPI(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("loading --openapi %s: %w", path, err)
+		}
+	}
+
+	for _, arg := range crdPaths {
+		files, err := input.Files([]string{arg}, false)
+		if err != nil {
+			return nil, fmt.Errorf("loading --crd: %w", err)
+		}
+		loaded := 0
+		for _, path := range files {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("loading --crd %s: %w", path, err)
+			}
+			n, err := schemas.LoadCRDs(data)
+			if err != nil {
+				return nil, fmt.Errorf("loading --crd %s: %w", path, err)
+			}
+			loaded += n
+		}
+		if loaded == 0 {
+			return nil, fmt.Errorf("loading --crd %s: no CustomResourceDefinition schemas found", arg)
+		}
+	}
+	return schemas, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/ahmetb/kubectl-fields/internal/output"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"go.yaml.in/yaml/v3"
+)
+
+// documentWriter encodes, post-processes (align + colorize) and writes
+// documents one at a time, so each document is printed as soon as it has
+// been annotated.
+type documentWriter struct {
+	w            io.Writer
+	format       parser.Format
+	buf          bytes.Buffer
+	enc          *parser.Encoder
+	colorEnabled bool
+	colorMgr     *output.ColorManager
+}
+
+func newDocumentWriter(w io.Writer, format parser.Format, colorEnabled bool, colorMgr *output.ColorManager) *documentWriter {
+	dw := &documentWriter{
+		w:            w,
+		format:       format,
+		colorEnabled: colorEnabled,
+		colorMgr:     colorMgr,
+	}
+	dw.enc = parser.NewEncoder(&dw.buf, format)
+	return dw
+}
+
+// write encodes a single document and writes the formatted result.
+func (dw *documentWriter) write(doc *yaml.Node) error {
+	return dw.flush(doc.HeadComment, func() error { return dw.enc.Encode(doc) })
+}
+
+// beginList writes the envelope fields preceding the items of a List.
+func (dw *documentWriter) beginList(header *yaml.Node) error {
+	return dw.flush(header.HeadComment, func() error { return dw.enc.BeginList(header) })
+}
+
+// writeItem writes one List item nested inside the List's items.
+func (dw *documentWriter) writeItem(item *yaml.Node) error {
+	return dw.flush(item.HeadComment, func() error { return dw.enc.EncodeItem(item) })
+}
+
+// endList writes the envelope fields following the items of a List.
+func (dw *documentWriter) endList(trailer *yaml.Node) error {
+	return dw.flush("", func() error { return dw.enc.EndList(trailer) })
+}
+
+// startDocument drops the color records of annotations that were never
+// written, before the next input document is annotated, so that they do
+// not pile up over a long stream.
+func (dw *documentWriter) startDocument() {
+	if dw.colorMgr != nil {
+		dw.colorMgr.Reset()
+	}
+}
+
+// flush runs encode against the buffer and writes the formatted result.
+// header is the head comment of the document being written, such as its
+// "Source:" line, whose lines are notes rather than owner annotations.
+func (dw *documentWriter) flush(header string, encode func() error) error {
+	dw.buf.Reset()
+	if err := encode(); err != nil {
+		return err
+	}
+	if dw.colorEnabled && header != "" {
+		for _, line := range strings.Split(header, "\n") {
+			dw.colorMgr.Note(line)
+		}
+	}
+
+	var result string
+	if dw.format == parser.FormatJSON {
+		result = output.FormatJSONOutput(dw.buf.String(), dw.colorEnabled, dw.colorMgr)
+	} else {
+		result = output.FormatOutput(dw.buf.String(), dw.colorEnabled, dw.colorMgr)
+	}
+	_, err := io.WriteString(dw.w, result)
+	return err
+}
@@ -2,10 +2,12 @@ package annotate
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
 	"github.com/ahmetb/kubectl-fields/internal/timeutil"
 	"go.yaml.in/yaml/v3"
 )
@@ -26,10 +28,75 @@ const (
 
 // Options configures annotation behaviour.
 type Options struct {
-	Above         bool      // true = HeadComment above field key, false = LineComment inline
-	Now           time.Time // current time for relative timestamps (enables deterministic tests)
-	Mtime         MtimeMode // timestamp display mode (default empty string treated as relative)
-	ShowOperation bool      // true = append lowercase operation type (apply, update) to annotations
+	Above         bool       // true = HeadComment above field key, false = LineComment inline
+	Now           time.Time  // current time for relative timestamps (enables deterministic tests)
+	Mtime         MtimeMode  // timestamp display mode (default empty string treated as relative)
+	ShowOperation bool       // true = append lowercase operation type (apply, update) to annotations
+	OwnerOrder    OwnerOrder // order of co-owners of a field (default empty string treated as time)
+	Previous      Ownership  // ownership of the previous revision; when non-nil, fields whose owner or time changed are marked
+
+	// Request is the managedFields entry written by an audited request;
+	// when non-nil, fields it owns are marked "[this request]".
+	Request *managed.ManagedFieldsEntry
+
+	// Inherit annotates the fields inside an atomically owned container that
+	// have no owner of their own with the container's owners, marked
+	// "[inherited]".
+	Inherit bool
+
+	// Unowned marks the fields no manager owns with UnownedComment.
+	Unowned bool
+
+	// Leaves, when non-nil, receives the number of owned and unowned leaves.
+	Leaves *LeafCounts
+
+	// Warn, when non-nil, is called for each k: element of an entry that
+	// matches no item of the list it addresses.
+	Warn func(msg string)
+
+	// Schema, when non-nil, is the schema of the object. The defaults of
+	// list-map keys it declares are used to match k: elements against list
+	// items that omit a defaulted key field.
+	Schema *schema.Schema
+
+	// Pruned lists the paths of the fields that applying a new manifest
+	// would delete (see FindPrunes); they and the fields inside them are
+	// marked with PrunedMark.
+	Pruned []string
+
+	// Semantics appends the server-side apply semantics that Schema declares
+	// for containers to their annotations, such as "[list-type: atomic]".
+	Semantics bool
+
+	// Format, when non-nil, renders the owners of each field instead of
+	// the built-in layout; Mtime and ShowOperation are then unused.
+	Format *Template
+
+	// FormatError, when non-nil, is called with the error of each field
+	// whose annotation Format fails to render; the field is then annotated
+	// with the built-in layout.
+	FormatError func(err error)
+
+	// Rendered, when non-nil, is called with each annotation laid out by
+	// Format, including its marks, and the manager of its primary owner,
+	// which the annotation text may not show. It is called once all of an
+	// object's annotations are in place, in the order they are written.
+	Rendered func(comment, manager string)
+
+	// Recent, when non-zero, marks the fields with an owner updated at or
+	// after it with RecentMark.
+	Recent time.Time
+}
+
+// RecentMark is appended to the annotation of fields with an owner updated
+// recently (see Options.Recent).
+const RecentMark = " [recent]"
+
+// isRecent reports whether any of owners was updated at or after since.
+func isRecent(owners []AnnotationInfo, since time.Time) bool {
+	return !since.IsZero() && slices.ContainsFunc(owners, func(info AnnotationInfo) bool {
+		return !info.Time.Before(since)
+	})
 }
 
 // effectiveMtime returns the effective mtime mode, treating empty string as relative.
@@ -40,6 +107,15 @@ func (o Options) effectiveMtime() MtimeMode {
 	return o.Mtime
 }
 
+// effectiveOwnerOrder returns the effective owner order, treating empty
+// string as time.
+func (o Options) effectiveOwnerOrder() OwnerOrder {
+	if o.OwnerOrder == "" {
+		return OrderTime
+	}
+	return o.OwnerOrder
+}
+
 // Annotate injects ownership comments into a YAML resource tree based on
 // managedFields entries. The root should be the resource MappingNode (not a
 // DocumentNode -- the caller must unwrap it first).
@@ -49,24 +125,100 @@ func (o Options) effectiveMtime() MtimeMode {
 //     building a map of annotation targets keyed by ValueNode pointer.
 //  2. Inject: for each target, set LineComment (inline) or HeadComment (above)
 //     on the appropriate node.
-func Annotate(root *yaml.Node, entries []managed.ManagedFieldsEntry, opts Options) {
+//
+// A field listed by several entries is annotated with all of its owners,
+// ordered by opts.OwnerOrder; the first of them is the field's owner in the
+// returned Ownership.
+//
+// It returns the ownership of every annotated field keyed by field path, which
+// can be passed as Options.Previous when annotating the next revision of the
+// same object.
+func Annotate(root *yaml.Node, entries []managed.ManagedFieldsEntry, opts Options) Ownership {
 	targets := make(map[*yaml.Node]AnnotationTarget)
+	paths := fieldPaths(root)
+
+	var schemas map[*yaml.Node]*schema.Schema
+	if opts.Schema != nil {
+		schemas = nodeSchemas(root, opts.Schema)
+	}
 
 	// Pass 1 -- Collect targets from all managed fields entries.
 	for _, entry := range entries {
 		if entry.FieldsV1 == nil {
 			continue
 		}
-		walkFieldsV1(root, nil, entry.FieldsV1, entry, targets)
+		w := &walkOptions{}
+		if schemas != nil {
+			w.keyDefaults = keyDefaults(schemas)
+		}
+		if opts.Warn != nil {
+			w.unresolved = func(node, _ *yaml.Node, key string, _ *yaml.Node) {
+				if prefix, _ := managed.ParseFieldsV1Key(key); prefix == "k" && node.Kind == yaml.SequenceNode {
+					opts.Warn(fmt.Sprintf("managedFields entry of %q: %s matches no item of %s", entry.Manager, key, displayPath(paths[node])))
+				}
+			}
+		}
+		walkFieldsV1(root, nil, entry.FieldsV1, entry, targets, w)
 	}
 
-	mtime := opts.effectiveMtime()
+	var request AnnotationInfo
+	if opts.Request != nil {
+		request = annotationFrom(*opts.Request)
+	}
+
+	var labels map[*yaml.Node]semanticLabel
+	if opts.Semantics {
+		labels = semanticLabels(root, schemas)
+	}
+
+	owned := make(Ownership, len(targets))
+	rendered := make(renderedComments)
 
 	// Pass 2 -- Inject comments.
 	for _, target := range targets {
-		comment := formatComment(target.Info, opts.Now, mtime, opts.ShowOperation)
+		owners := orderOwners(target.Info, target.CoOwners, opts.effectiveOwnerOrder())
+		path := paths[target.ValueNode]
+		owned[path] = owners[0]
+
+		comment := opts.ownersComment(owners)
+		if opts.Previous != nil {
+			comment += changeMark(opts.Previous, path, owners[0])
+		}
+		if opts.Request != nil && slices.ContainsFunc(owners, func(info AnnotationInfo) bool {
+			return sameEntry(info, request)
+		}) {
+			comment += " [this request]"
+		}
+		if isPruned(opts.Pruned, path) {
+			comment += PrunedMark
+		}
+		if isRecent(owners, opts.Recent) {
+			comment += RecentMark
+		}
+		comment += labelMark(labels, target.ValueNode)
 		injectComment(target, comment, opts.Above)
+		rendered.add(opts, target.ValueNode, comment, owners)
+	}
+
+	if opts.Inherit {
+		inheritOwnership(root, targets, labels, rendered, opts)
+	}
+	rendered.report(opts, root)
+	if opts.Unowned || opts.Leaves != nil {
+		counts := markUnowned(root, targets, labels, opts.Above, opts.Unowned)
+		if opts.Leaves != nil {
+			*opts.Leaves = counts
+		}
 	}
+	injectLabels(labels, opts.Above)
+	return owned
+}
+
+// sameEntry reports whether two AnnotationInfo values come from the same
+// managedFields entry.
+func sameEntry(a, b AnnotationInfo) bool {
+	return a.Manager == b.Manager && a.Operation == b.Operation &&
+		a.Subresource == b.Subresource && a.Time.Equal(b.Time)
 }
 
 // injectComment places a comment on the appropriate node based on mode and
@@ -2,6 +2,7 @@ package annotate
 
 import (
 	"bytes"
+	"io"
 	"os"
 	"strings"
 	"testing"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/ahmetb/kubectl-fields/internal/managed"
 	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.yaml.in/yaml/v3"
@@ -550,11 +552,13 @@ func processDeploymentFixture(t *testing.T, above bool, showOperation bool, fixe
 	inputData, err := os.ReadFile("../../testdata/1_deployment.yaml")
 	require.NoError(t, err, "reading deployment fixture")
 
-	docs, err := parser.ParseDocuments(bytes.NewReader(inputData))
+	dec, err := parser.NewDecoder(bytes.NewReader(inputData))
+	require.NoError(t, err)
+	tok, err := dec.Next()
 	require.NoError(t, err, "parsing deployment fixture")
-	require.Len(t, docs, 1, "expected 1 document")
+	require.Equal(t, parser.DocumentToken, tok.Type, "expected a single document")
 
-	doc := docs[0]
+	doc := tok.Node
 	require.Equal(t, yaml.DocumentNode, doc.Kind)
 	require.NotEmpty(t, doc.Content)
 	root := doc.Content[0]
@@ -689,6 +693,42 @@ data:
 	assert.Contains(t, output, "key: value", "original data should be preserved")
 }
 
+func TestAnnotate_PartialObjectMetadataListItems(t *testing.T) {
+	// Items of a PartialObjectMetadataList only carry metadata; each item is
+	// annotated from its own managedFields, and FieldsV1 paths outside
+	// metadata are ignored.
+	data, err := os.ReadFile("../../testdata/lists/partial_metadata_list.json")
+	require.NoError(t, err)
+
+	dec, err := parser.NewDecoder(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	var outputs []string
+	for {
+		tok, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if tok.Type != parser.ListItemToken {
+			continue
+		}
+		root := tok.Node.Content[0]
+
+		entries, err := managed.ExtractManagedFields(root)
+		require.NoError(t, err)
+		Annotate(root, entries, Options{Now: testNow, Mtime: MtimeHide})
+		managed.StripManagedFields(root)
+		outputs = append(outputs, encodeYAML(t, root))
+	}
+
+	require.Len(t, outputs, 2)
+	assert.Contains(t, outputs[0], "labels: # kubectl-client-side-apply")
+	assert.Contains(t, outputs[0], "app: nginx # kubectl-client-side-apply")
+	assert.Contains(t, outputs[1], "app: redis # helm")
+	assert.NotContains(t, outputs[1], "kubectl-client-side-apply")
+}
+
 // --- helpers ---
 
 // buildFieldsV1 parses a JSON FieldsV1 string into a yaml.Node MappingNode
@@ -702,3 +742,338 @@ func buildFieldsV1(t *testing.T, jsonStr string) *yaml.Node {
 	require.NotEmpty(t, node.Content)
 	return node.Content[0]
 }
+
+func TestAnnotate_MarksRequestEntry(t *testing.T) {
+	root := parseYAML(t, "replicas: 3\nimage: nginx\n")
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "kubectl-apply",
+			Time:     testNow.Add(-time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:image":{}}`),
+		},
+		{
+			Manager:  "hpa",
+			Time:     testNow.Add(-time.Minute),
+			FieldsV1: buildFieldsV1(t, `{"f:replicas":{}}`),
+		},
+	}
+
+	Annotate(root, entries, Options{Now: testNow, Request: &entries[1]})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "replicas: 3 # hpa (1m ago) [this request]")
+	assert.Contains(t, output, "image: nginx # kubectl-apply (1h ago)\n")
+}
+
+// coOwnedEntries returns entries for three managers sharing "replicas".
+func coOwnedEntries(t *testing.T) []managed.ManagedFieldsEntry {
+	t.Helper()
+	return []managed.ManagedFieldsEntry{
+		{
+			Manager:   "helm",
+			Operation: "Apply",
+			Time:      testNow.Add(-2 * time.Hour),
+			FieldsV1:  buildFieldsV1(t, `{"f:replicas":{},"f:image":{}}`),
+		},
+		{
+			Manager:   "hpa",
+			Operation: "Update",
+			Time:      testNow.Add(-time.Minute),
+			FieldsV1:  buildFieldsV1(t, `{"f:replicas":{}}`),
+		},
+		{
+			Manager:   "argocd",
+			Operation: "Apply",
+			Time:      testNow.Add(-time.Hour),
+			FieldsV1:  buildFieldsV1(t, `{"f:replicas":{}}`),
+		},
+	}
+}
+
+func TestAnnotate_CoOwnersByTime(t *testing.T) {
+	root := parseYAML(t, "replicas: 3\nimage: nginx\n")
+
+	owned := Annotate(root, coOwnedEntries(t), Options{Now: testNow})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "replicas: 3 # hpa (1m ago) + argocd (1h ago) + helm (2h ago)\n")
+	assert.Contains(t, output, "image: nginx # helm (2h ago)\n")
+	assert.Equal(t, "hpa", owned[".replicas"].Manager)
+}
+
+func TestAnnotate_CoOwnersApplyFirst(t *testing.T) {
+	root := parseYAML(t, "replicas: 3\n")
+
+	owned := Annotate(root, coOwnedEntries(t), Options{Now: testNow, OwnerOrder: OrderApply, ShowOperation: true})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "replicas: 3 # argocd (1h ago, apply) + helm (2h ago, apply) + hpa (1m ago, update)\n")
+	assert.Equal(t, "argocd", owned[".replicas"].Manager)
+}
+
+func TestAnnotate_CoOwnersStableOnEqualTimes(t *testing.T) {
+	root := parseYAML(t, "replicas: 3\n")
+	entries := coOwnedEntries(t)
+	for i := range entries {
+		entries[i].Time = testNow.Add(-time.Hour)
+	}
+
+	Annotate(root, entries, Options{Now: testNow, Mtime: MtimeHide})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "replicas: 3 # helm + hpa + argocd\n")
+}
+
+func TestAnnotate_MarksRequestEntryAmongCoOwners(t *testing.T) {
+	root := parseYAML(t, "replicas: 3\n")
+	entries := coOwnedEntries(t)
+
+	Annotate(root, entries, Options{Now: testNow, Mtime: MtimeHide, Request: &entries[0]})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "replicas: 3 # hpa + argocd + helm [this request]\n")
+}
+
+func TestAnnotate_InlineIndexItems(t *testing.T) {
+	root := parseYAML(t, "spec:\n  args:\n  - --verbose\n  - --port=80\n  rules:\n  - host: a\n  - host: b\n")
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "operator",
+			Time:     testNow.Add(-time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:spec":{"f:args":{"i:1":{}},"f:rules":{"i:0":{"f:host":{}}}}}`),
+		},
+	}
+
+	owned := Annotate(root, entries, Options{Now: testNow})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "- --verbose\n")
+	assert.Contains(t, output, "- --port=80 # operator (1h ago)\n")
+	assert.Contains(t, output, "- host: a # operator (1h ago)\n")
+	assert.Contains(t, output, "- host: b\n")
+	assert.Contains(t, owned, ".spec.rules[0].host")
+}
+
+func TestAnnotate_TypedAssociativeKeys(t *testing.T) {
+	root := parseYAML(t, `spec:
+  ports:
+  - port: 0x50
+    name: http
+  - port: "443"
+    name: https
+  refs:
+  - {group: apps, kind: Deployment}
+  - ref: {group: apps, kind: StatefulSet}
+`)
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager: "operator",
+			Time:    testNow.Add(-time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:spec":{
+				"f:ports":{"k:{\"port\":80}":{"f:name":{}},"k:{\"port\":\"443\"}":{"f:name":{}}},
+				"f:refs":{
+					"k:{\"ref\":{\"group\":\"apps\",\"kind\":\"Deployment\"}}":{},
+					"k:{\"ref\":{\"group\":\"apps\",\"kind\":\"StatefulSet\"}}":{}}}}`),
+		},
+	}
+
+	var warnings []string
+	owned := Annotate(root, entries, Options{Now: testNow, Warn: func(msg string) { warnings = append(warnings, msg) }})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, owned, ".spec.refs[1]")
+	assert.Contains(t, output, "name: http # operator (1h ago)")
+	assert.Contains(t, output, "name: https # operator (1h ago)")
+	assert.Equal(t, []string{
+		`managedFields entry of "operator": k:{"ref":{"group":"apps","kind":"Deployment"}} matches no item of .spec.refs`,
+	}, warnings)
+}
+
+func TestAnnotate_Inherit(t *testing.T) {
+	root := parseYAML(t, `spec:
+  selector:
+    matchLabels:
+      app: web
+  ports:
+  - name: http
+    protocol: TCP
+`)
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "kubectl",
+			Time:     testNow.Add(-time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:spec":{"f:selector":{},"f:ports":{"k:{\"name\":\"http\"}":{".":{},"f:name":{}}}}}`),
+		},
+	}
+
+	Annotate(root, entries, Options{Now: testNow, Mtime: MtimeHide, Inherit: true})
+	output := encodeYAML(t, root)
+
+	// The list item is only claimed to exist, so protocol is not inherited.
+	assert.Equal(t, `spec:
+  selector: # kubectl
+    matchLabels: # kubectl [inherited]
+      app: web # kubectl [inherited]
+  ports:
+  - # kubectl
+    name: http # kubectl
+    protocol: TCP
+`, output)
+}
+
+func TestAnnotate_InheritCountsAsOwned(t *testing.T) {
+	root := parseYAML(t, "spec:\n  selector:\n    matchLabels:\n      app: web\n  replicas: 1\n")
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "kubectl",
+			Time:     testNow,
+			FieldsV1: buildFieldsV1(t, `{"f:spec":{"f:selector":{}}}`),
+		},
+	}
+
+	var leaves LeafCounts
+	Annotate(root, entries, Options{Now: testNow, Mtime: MtimeHide, Inherit: true, Unowned: true, Leaves: &leaves})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "app: web # kubectl [inherited]\n")
+	assert.Contains(t, output, "replicas: 1 # (unowned)\n")
+	assert.Equal(t, LeafCounts{Owned: 1, Unowned: 1}, leaves)
+}
+
+func TestAnnotate_InheritSkipsExistenceOwnedContainers(t *testing.T) {
+	// A webhook label added under labels owned through "." stays unowned.
+	root := parseYAML(t, `metadata:
+  labels:
+    app: web
+    injected-by-webhook: "yes"
+`)
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "kubectl-client-side-apply",
+			Time:     testNow,
+			FieldsV1: buildFieldsV1(t, `{"f:metadata":{"f:labels":{".":{},"f:app":{}}}}`),
+		},
+	}
+
+	var leaves LeafCounts
+	Annotate(root, entries, Options{Now: testNow, Mtime: MtimeHide, Inherit: true, Unowned: true, Leaves: &leaves})
+	output := encodeYAML(t, root)
+
+	assert.Equal(t, `metadata:
+  labels: # kubectl-client-side-apply
+    app: web # kubectl-client-side-apply
+    injected-by-webhook: "yes" # (unowned)
+`, output)
+	assert.Equal(t, LeafCounts{Owned: 1, Unowned: 1}, leaves)
+}
+
+func podEntries(t *testing.T) []managed.ManagedFieldsEntry {
+	t.Helper()
+	return []managed.ManagedFieldsEntry{
+		{
+			Manager: "kubectl",
+			Time:    testNow.Add(-time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:spec":{"f:containers":{"k:{\"name\":\"web\"}":{".":{},"f:name":{},`+
+				`"f:args":{},"f:ports":{"k:{\"containerPort\":80,\"protocol\":\"TCP\"}":{".":{},"f:containerPort":{}}}}}}}`),
+		},
+	}
+}
+
+const podYAML = `apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - name: web
+    args:
+    - serve
+    ports:
+    - containerPort: 80
+  nodeSelector:
+    disk: ssd
+`
+
+func TestAnnotate_SchemaKeyDefaults(t *testing.T) {
+	// The port omits its protocol, which the server defaulted to TCP when
+	// computing the key.
+	root := parseYAML(t, podYAML)
+	var warnings []string
+	Annotate(root, podEntries(t), Options{
+		Now:    testNow,
+		Mtime:  MtimeHide,
+		Schema: schema.Builtin().ForKind("v1", "Pod"),
+		Warn:   func(msg string) { warnings = append(warnings, msg) },
+	})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "      containerPort: 80 # kubectl\n")
+	assert.Empty(t, warnings)
+
+	// Without the schema, the key matches no item.
+	root = parseYAML(t, podYAML)
+	Annotate(root, podEntries(t), Options{Now: testNow, Mtime: MtimeHide, Warn: func(msg string) { warnings = append(warnings, msg) }})
+	assert.NotContains(t, encodeYAML(t, root), "containerPort: 80 # kubectl")
+	assert.Len(t, warnings, 1)
+}
+
+func TestAnnotate_Semantics(t *testing.T) {
+	root := parseYAML(t, podYAML)
+	Annotate(root, podEntries(t), Options{
+		Now:       testNow,
+		Mtime:     MtimeHide,
+		Schema:    schema.Builtin().ForKind("v1", "Pod"),
+		Semantics: true,
+	})
+	output := encodeYAML(t, root)
+
+	assert.Equal(t, `apiVersion: v1
+kind: Pod
+spec:
+  containers: # [list-type: map keys=[name]]
+  - # kubectl
+    name: web # kubectl
+    args: # kubectl [list-type: atomic]
+    - serve
+    ports: # [list-type: map keys=[containerPort,protocol]]
+    - # kubectl
+      containerPort: 80 # kubectl
+  nodeSelector: # [map-type: atomic]
+    disk: ssd
+`, output)
+}
+
+func TestAnnotate_SemanticsWithUnowned(t *testing.T) {
+	root := parseYAML(t, podYAML)
+	Annotate(root, podEntries(t), Options{
+		Now:       testNow,
+		Mtime:     MtimeHide,
+		Schema:    schema.Builtin().ForKind("v1", "Pod"),
+		Semantics: true,
+		Unowned:   true,
+	})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "  nodeSelector: # (unowned) [map-type: atomic]\n")
+}
+
+func TestAnnotate_Recent(t *testing.T) {
+	root := parseYAML(t, "replicas: 3\nimage: nginx\npaused: false\n")
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "helm",
+			Time:     testNow.Add(-2 * time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:replicas":{},"f:image":{}}`),
+		},
+		{
+			Manager:  "hpa",
+			Time:     testNow.Add(-10 * time.Minute),
+			FieldsV1: buildFieldsV1(t, `{"f:replicas":{},"f:paused":{}}`),
+		},
+	}
+
+	Annotate(root, entries, Options{Now: testNow, Mtime: MtimeHide, OwnerOrder: OrderApply, Recent: testNow.Add(-time.Hour)})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "replicas: 3 # hpa + helm [recent]\n")
+	assert.Contains(t, output, "image: nginx # helm\n")
+	assert.Contains(t, output, "paused: false # hpa [recent]\n")
+}
@@ -0,0 +1,73 @@
+package annotate
+
+import (
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Ownership maps field paths (such as ".spec.containers[0].image") to the
+// ownership info of the field, for comparing revisions of the same object.
+type Ownership map[string]AnnotationInfo
+
+// changeMark returns the suffix appended to the comment of a field whose
+// ownership differs from the previous revision:
+//
+//	" [changed]"              new field, or same owner with a newer timestamp
+//	" [changed from kubectl]" owner changed (kubectl owned it before)
+//
+// It returns "" when the field is unchanged.
+func changeMark(previous Ownership, path string, info AnnotationInfo) string {
+	prev, ok := previous[path]
+	switch {
+	case !ok:
+		return " [changed]"
+	case prev.Manager != info.Manager || prev.Subresource != info.Subresource:
+		return " [changed from " + prev.Manager + "]"
+	case prev.Operation != info.Operation || !prev.Time.Equal(info.Time):
+		return " [changed]"
+	default:
+		return ""
+	}
+}
+
+// fieldPaths returns the path of every node in the tree under root. Mapping
+// fields are addressed as ".name" (or `["name"]` when the name contains
+// characters that would make the path ambiguous) and sequence items as "[i]".
+// The root itself has the empty path.
+func fieldPaths(root *yaml.Node) map[*yaml.Node]string {
+	paths := make(map[*yaml.Node]string)
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		paths[node] = path
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i < len(node.Content)-1; i += 2 {
+				walk(node.Content[i+1], path+pathField(node.Content[i].Value))
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				walk(item, path+"["+strconv.Itoa(i)+"]")
+			}
+		}
+	}
+	walk(root, "")
+	return paths
+}
+
+// displayPath renders a field path for messages, with "." for the root.
+func displayPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+// pathField renders a mapping key as a path element.
+func pathField(name string) string {
+	if name == "" || strings.ContainsAny(name, ".[]") {
+		return "[" + strconv.Quote(name) + "]"
+	}
+	return "." + name
+}
@@ -0,0 +1,97 @@
+package annotate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldPaths(t *testing.T) {
+	root := parseYAML(t, `spec:
+  replicas: 3
+  containers:
+  - name: app
+    ports:
+    - containerPort: 80
+metadata:
+  labels:
+    app.kubernetes.io/name: web
+`)
+	paths := fieldPaths(root)
+
+	got := make(map[string]bool)
+	for _, p := range paths {
+		got[p] = true
+	}
+	for _, want := range []string{
+		"",
+		".spec",
+		".spec.replicas",
+		".spec.containers[0]",
+		".spec.containers[0].name",
+		".spec.containers[0].ports[0].containerPort",
+		`.metadata.labels["app.kubernetes.io/name"]`,
+	} {
+		assert.True(t, got[want], "missing path %q", want)
+	}
+}
+
+func TestChangeMark(t *testing.T) {
+	ts := testNow.Add(-time.Hour)
+	previous := Ownership{
+		".spec.replicas": {Manager: "kubectl", Operation: "Update", Time: ts},
+	}
+
+	assert.Equal(t, "", changeMark(previous, ".spec.replicas", AnnotationInfo{Manager: "kubectl", Operation: "Update", Time: ts}))
+	assert.Equal(t, " [changed]", changeMark(previous, ".spec.replicas", AnnotationInfo{Manager: "kubectl", Operation: "Update", Time: testNow}))
+	assert.Equal(t, " [changed from kubectl]", changeMark(previous, ".spec.replicas", AnnotationInfo{Manager: "hpa", Operation: "Update", Time: ts}))
+	assert.Equal(t, " [changed]", changeMark(previous, ".spec.paused", AnnotationInfo{Manager: "kubectl", Time: ts}))
+}
+
+func TestAnnotate_MarksChangesSincePrevious(t *testing.T) {
+	input := "replicas: 3\nimage: nginx\n"
+	first := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "kubectl-apply",
+			Time:     testNow.Add(-time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:replicas":{},"f:image":{}}`),
+		},
+	}
+	previous := Annotate(parseYAML(t, input), first, Options{Now: testNow})
+	assert.Equal(t, "kubectl-apply", previous[".replicas"].Manager)
+	assert.Equal(t, "kubectl-apply", previous[".image"].Manager)
+
+	second := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "kubectl-apply",
+			Time:     testNow.Add(-time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:image":{}}`),
+		},
+		{
+			Manager:  "hpa",
+			Time:     testNow.Add(-time.Minute),
+			FieldsV1: buildFieldsV1(t, `{"f:replicas":{}}`),
+		},
+	}
+	root := parseYAML(t, input)
+	Annotate(root, second, Options{Now: testNow, Previous: previous})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "replicas: 3 # hpa (1m ago) [changed from kubectl-apply]")
+	assert.Contains(t, output, "image: nginx # kubectl-apply (1h ago)\n")
+}
+
+func TestAnnotate_NoPreviousNoMarks(t *testing.T) {
+	root := parseYAML(t, "replicas: 3\n")
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "kubectl-apply",
+			Time:     testNow.Add(-time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:replicas":{}}`),
+		},
+	}
+	Annotate(root, entries, Options{Now: testNow})
+	assert.NotContains(t, encodeYAML(t, root), "changed")
+}
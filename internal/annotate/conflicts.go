@@ -0,0 +1,211 @@
+package annotate
+
+import (
+	"reflect"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"go.yaml.in/yaml/v3"
+)
+
+// ConflictMark is appended to the annotation of manifest fields that would
+// conflict when applied (see FindConflicts).
+const ConflictMark = " [conflict]"
+
+// Conflict is a field of an apply manifest that other managers own in the
+// live object with a different value, so that a server-side apply of the
+// manifest would fail unless forced.
+type Conflict struct {
+	Path   string           // path of the field in the manifest, e.g. ".spec.replicas"
+	Owners []AnnotationInfo // the managers owning the live field, ordered by time
+	Live   *yaml.Node       // the live field
+
+	key   *yaml.Node // key of the manifest field in its parent, if any
+	value *yaml.Node // the manifest field
+}
+
+// FindConflicts predicts the conflicts of applying the manifest root
+// MappingNode as manager to the live root MappingNode, whose managedFields
+// are entries. A manifest field conflicts when a managedFields entry other
+// than manager's Apply entry owns it in the live object, as a FieldsV1 leaf,
+// with a different value; as in server-side apply, an Update entry of the
+// same manager counts as another manager. Entries of subresources (such as
+// status) are ignored, as applying to the main resource does not change
+// their fields.
+//
+// Manifest fields are matched with live fields by name, list items by their
+// list-map keys (or value, for sets) according to the schema s of the object.
+// Without a schema, list items are matched by their name field. Conflicts
+// are returned in manifest order.
+func FindConflicts(manifest, live *yaml.Node, entries []managed.ManagedFieldsEntry, manager string, s *schema.Schema) []Conflict {
+	targets := make(map[*yaml.Node]AnnotationTarget)
+	w := &walkOptions{}
+	if s != nil {
+		w.keyDefaults = keyDefaults(nodeSchemas(live, s))
+	}
+	for _, entry := range entries {
+		if entry.FieldsV1 == nil || entry.Subresource != "" ||
+			(entry.Manager == manager && entry.Operation == "Apply") {
+			continue
+		}
+		walkFieldsV1(live, nil, entry.FieldsV1, entry, targets, w)
+	}
+
+	paths := fieldPaths(manifest)
+	var conflicts []Conflict
+	var walk func(key, m, l *yaml.Node, s *schema.Schema)
+	walk = func(key, m, l *yaml.Node, s *schema.Schema) {
+		if target, ok := targets[l]; ok && target.Atomic {
+			if !sameValue(m, l) {
+				conflicts = append(conflicts, Conflict{
+					Path:   paths[m],
+					Owners: orderOwners(target.Info, target.CoOwners, OrderTime),
+					Live:   l,
+					key:    key,
+					value:  m,
+				})
+			}
+			return
+		}
+
+		switch {
+		case m.Kind == yaml.MappingNode && l.Kind == yaml.MappingNode:
+			for i := 0; i < len(m.Content)-1; i += 2 {
+				name := m.Content[i].Value
+				if _, lv := findMappingField(l, name); lv != nil {
+					walk(m.Content[i], m.Content[i+1], lv, s.Field(name))
+				}
+			}
+		case m.Kind == yaml.SequenceNode && l.Kind == yaml.SequenceNode:
+			for _, item := range m.Content {
+				if li := matchListItem(item, l, s); li != nil {
+					walk(nil, item, li, s.Item())
+				}
+			}
+		}
+	}
+	walk(nil, manifest, live, s)
+	return conflicts
+}
+
+// matchListItem returns the item of the live sequence that corresponds to
+// item of a manifest sequence with schema s, or nil.
+func matchListItem(item, live *yaml.Node, s *schema.Schema) *yaml.Node {
+	listType, keys := s.List()
+	switch {
+	case listType == "map":
+		defaults := s.KeyDefaults()
+		key := make(map[string]any, len(keys))
+		for _, k := range keys {
+			if _, v := findMappingField(item, k); v != nil {
+				key[k] = nodeValue(v)
+			} else if def, ok := defaults[k]; ok {
+				key[k] = def
+			} else {
+				return nil
+			}
+		}
+		for _, li := range live.Content {
+			if matchesKeyValues(li, key, defaults) {
+				return li
+			}
+		}
+	case listType == "set":
+		for _, li := range live.Content {
+			if sameValue(item, li) {
+				return li
+			}
+		}
+	case listType == "" && s == nil:
+		// No schema: most lists of objects are keyed by name.
+		_, name := findMappingField(item, "name")
+		if name == nil {
+			return nil
+		}
+		for _, li := range live.Content {
+			if _, n := findMappingField(li, "name"); n != nil && sameValue(name, n) {
+				return li
+			}
+		}
+	}
+	return nil
+}
+
+// matchesKeyValues reports whether the fields of the MappingNode item (or
+// their defaults) equal the decoded values in key.
+func matchesKeyValues(item *yaml.Node, key, defaults map[string]any) bool {
+	if item.Kind != yaml.MappingNode {
+		return false
+	}
+	for k, want := range key {
+		var got any
+		if _, v := findMappingField(item, k); v != nil {
+			got = nodeValue(v)
+		} else if def, ok := defaults[k]; ok {
+			got = def
+		} else {
+			return false
+		}
+		if !reflect.DeepEqual(normalizeNumber(got), normalizeNumber(want)) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameValue reports whether two YAML nodes hold the same value, regardless
+// of style and comments.
+func sameValue(a, b *yaml.Node) bool {
+	return reflect.DeepEqual(normalizeNumber(nodeValue(a)), normalizeNumber(nodeValue(b)))
+}
+
+// nodeValue decodes a node into plain Go values, or nil if it cannot be
+// decoded.
+func nodeValue(node *yaml.Node) any {
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// normalizeNumber converts the integer types YAML decodes into float64, the
+// type of JSON numbers and schema defaults, recursively.
+func normalizeNumber(v any) any {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case map[string]any:
+		out := make(map[string]any, len(n))
+		for k, item := range n {
+			out[k] = normalizeNumber(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(n))
+		for i, item := range n {
+			out[i] = normalizeNumber(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// InjectConflicts annotates the manifest fields of conflicts with their live
+// owners followed by ConflictMark, formatted as by Annotate with opts.
+// Conflicts are reported to opts.Rendered in the order given, which for
+// FindConflicts is document order.
+func InjectConflicts(conflicts []Conflict, opts Options) {
+	for _, c := range conflicts {
+		comment := opts.ownersComment(c.Owners) + ConflictMark
+		injectComment(AnnotationTarget{KeyNode: c.key, ValueNode: c.value}, comment, opts.Above)
+		if opts.Format != nil && opts.Rendered != nil {
+			opts.Rendered(comment, c.Owners[0].Manager)
+		}
+	}
+}
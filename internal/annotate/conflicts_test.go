@@ -0,0 +1,128 @@
+package annotate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const liveDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 5
+  selector:
+    matchLabels:
+      app: web
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:1
+        ports:
+        - containerPort: 80
+          protocol: TCP
+`
+
+func liveEntries(t *testing.T) []managed.ManagedFieldsEntry {
+	t.Helper()
+	return []managed.ManagedFieldsEntry{
+		{
+			Manager:   "argocd",
+			Operation: "Apply",
+			Time:      testNow.Add(-2 * time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:spec":{"f:selector":{},"f:template":{"f:spec":{"f:containers":{`+
+				`"k:{\"name\":\"web\"}":{".":{},"f:name":{},"f:image":{},"f:ports":{"k:{\"containerPort\":80,\"protocol\":\"TCP\"}":{".":{},"f:containerPort":{}}}}}}}}}`),
+		},
+		{
+			Manager:   "hpa",
+			Operation: "Update",
+			Time:      testNow.Add(-time.Hour),
+			FieldsV1:  buildFieldsV1(t, `{"f:spec":{"f:replicas":{}}}`),
+		},
+		{
+			Manager:     "kube-controller-manager",
+			Operation:   "Update",
+			Subresource: "status",
+			Time:        testNow,
+			FieldsV1:    buildFieldsV1(t, `{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"web\"}":{"f:image":{}}}}}}}`),
+		},
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	live := parseYAML(t, liveDeployment)
+	manifest := parseYAML(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  selector:
+    matchLabels:
+      app: web
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:2
+        ports:
+        - containerPort: 80
+`)
+	s := schema.Builtin().ForKind("apps/v1", "Deployment")
+
+	conflicts := FindConflicts(manifest, live, liveEntries(t), "deployer", s)
+	require.Len(t, conflicts, 2)
+	assert.Equal(t, ".spec.replicas", conflicts[0].Path)
+	assert.Equal(t, "hpa", conflicts[0].Owners[0].Manager)
+	assert.Equal(t, "5", conflicts[0].Live.Value)
+	assert.Equal(t, ".spec.template.spec.containers[0].image", conflicts[1].Path)
+	assert.Equal(t, "argocd", conflicts[1].Owners[0].Manager)
+
+	InjectConflicts(conflicts, Options{Now: testNow})
+	output := encodeYAML(t, manifest)
+	assert.Contains(t, output, "replicas: 3 # hpa (1h ago) [conflict]\n")
+	assert.Contains(t, output, "image: web:2 # argocd (2h ago) [conflict]\n")
+	assert.Contains(t, output, "- containerPort: 80\n", "equal values do not conflict")
+}
+
+func TestFindConflicts_OwnApplyEntry(t *testing.T) {
+	live := parseYAML(t, liveDeployment)
+	manifest := parseYAML(t, "spec:\n  template:\n    spec:\n      containers:\n      - name: web\n        image: web:2\n")
+
+	conflicts := FindConflicts(manifest, live, liveEntries(t), "argocd", schema.Builtin().ForKind("apps/v1", "Deployment"))
+	assert.Empty(t, conflicts, "argocd's own Apply entry does not conflict")
+}
+
+func TestFindConflicts_AtomicContainer(t *testing.T) {
+	live := parseYAML(t, liveDeployment)
+	manifest := parseYAML(t, "spec:\n  selector:\n    matchLabels:\n      app: api\n")
+
+	conflicts := FindConflicts(manifest, live, liveEntries(t), "deployer", schema.Builtin().ForKind("apps/v1", "Deployment"))
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, ".spec.selector", conflicts[0].Path)
+
+	InjectConflicts(conflicts, Options{Now: testNow, Mtime: MtimeHide})
+	assert.Contains(t, encodeYAML(t, manifest), "selector: # argocd [conflict]\n")
+}
+
+func TestFindConflicts_NoSchemaMatchesByName(t *testing.T) {
+	live := parseYAML(t, liveDeployment)
+	manifest := parseYAML(t, "spec:\n  template:\n    spec:\n      containers:\n      - name: web\n        image: web:2\n")
+
+	conflicts := FindConflicts(manifest, live, liveEntries(t), "deployer", nil)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, ".spec.template.spec.containers[0].image", conflicts[0].Path)
+}
+
+func TestFindConflicts_NewFields(t *testing.T) {
+	live := parseYAML(t, liveDeployment)
+	manifest := parseYAML(t, "spec:\n  paused: true\n  template:\n    spec:\n      containers:\n      - name: sidecar\n        image: proxy:1\n")
+
+	assert.Empty(t, FindConflicts(manifest, live, liveEntries(t), "deployer", schema.Builtin().ForKind("apps/v1", "Deployment")))
+}
@@ -0,0 +1,89 @@
+package annotate
+
+import (
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"go.yaml.in/yaml/v3"
+)
+
+// Extract returns a copy of the root MappingNode holding only the fields
+// that entries own, as a manifest their manager could apply: the identifying
+// fields (apiVersion, kind, metadata.name and metadata.namespace) and the key
+// fields of the list items addressed by key are kept too. Containers an entry
+// owns with a "." marker are kept even when empty. Fields are in the order of
+// root, without comments.
+//
+// List items are matched with the key defaults from the schema s of the
+// object (which may be nil), as in Annotate.
+func Extract(root *yaml.Node, entries []managed.ManagedFieldsEntry, s *schema.Schema) *yaml.Node {
+	// keep holds the nodes kept with all of their contents: the
+	// identifying fields and the key fields of list items.
+	keep := make(map[*yaml.Node]bool)
+	for _, name := range []string{"apiVersion", "kind"} {
+		if _, v := findMappingField(root, name); v != nil {
+			keep[v] = true
+		}
+	}
+	_, metadata := findMappingField(root, "metadata")
+	for _, name := range []string{"name", "namespace"} {
+		if _, v := findMappingField(metadata, name); v != nil {
+			keep[v] = true
+		}
+	}
+
+	w := &walkOptions{
+		keyed: func(item *yaml.Node, assocKey map[string]any) {
+			for field := range assocKey {
+				if _, v := findMappingField(item, field); v != nil {
+					keep[v] = true
+				}
+			}
+		},
+	}
+	if s != nil {
+		w.keyDefaults = keyDefaults(nodeSchemas(root, s))
+	}
+	targets := make(map[*yaml.Node]AnnotationTarget)
+	for _, entry := range entries {
+		if entry.FieldsV1 != nil {
+			walkFieldsV1(root, nil, entry.FieldsV1, entry, targets, w)
+		}
+	}
+
+	var extract func(node *yaml.Node) *yaml.Node
+	extract = func(node *yaml.Node) *yaml.Node {
+		target, owned := targets[node]
+		if keep[node] || owned && target.Atomic {
+			return parser.CloneNode(node, false)
+		}
+		switch node.Kind {
+		case yaml.MappingNode, yaml.SequenceNode:
+		default:
+			return nil
+		}
+
+		out := &yaml.Node{Kind: node.Kind, Tag: node.Tag, Style: node.Style &^ yaml.FlowStyle}
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i < len(node.Content)-1; i += 2 {
+				if node == metadata && node.Content[i].Value == "managedFields" {
+					continue
+				}
+				if v := extract(node.Content[i+1]); v != nil {
+					out.Content = append(out.Content, parser.CloneNode(node.Content[i], false), v)
+				}
+			}
+		} else {
+			for _, item := range node.Content {
+				if v := extract(item); v != nil {
+					out.Content = append(out.Content, v)
+				}
+			}
+		}
+		if len(out.Content) == 0 && !owned && node != root {
+			return nil
+		}
+		return out
+	}
+	return extract(root)
+}
@@ -0,0 +1,127 @@
+package annotate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+const extractDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+    team: payments # owned by kubectl
+  annotations:
+    deployment.kubernetes.io/revision: "3"
+  finalizers:
+  - example.com/foo
+  managedFields:
+  - manager: helm
+spec:
+  replicas: 3
+  selector:
+    matchLabels:
+      app: web
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:1
+        imagePullPolicy: IfNotPresent
+        ports:
+        - containerPort: 80
+          name: http
+        resources: {}
+      - name: sidecar
+        image: envoy
+status:
+  replicas: 3
+`
+
+func extractEntries(t *testing.T) []managed.ManagedFieldsEntry {
+	t.Helper()
+	return []managed.ManagedFieldsEntry{
+		{
+			Manager:   "helm",
+			Operation: "Apply",
+			Time:      testNow.Add(-time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:metadata":{"f:labels":{"f:app":{}},"f:finalizers":{"v:\"example.com/foo\"":{}}},`+
+				`"f:spec":{"f:replicas":{},"f:selector":{},"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"web\"}":{".":{},"f:name":{},"f:image":{},"f:resources":{".":{}},`+
+				`"f:ports":{"k:{\"containerPort\":80,\"protocol\":\"TCP\"}":{".":{},"f:name":{}}}}}}}}}`),
+		},
+		{
+			Manager:   "kubectl",
+			Operation: "Update",
+			Time:      testNow,
+			FieldsV1:  buildFieldsV1(t, `{"f:metadata":{"f:labels":{"f:team":{}}},"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"sidecar\"}":{".":{},"f:name":{},"f:image":{}}}}}}}`),
+		},
+	}
+}
+
+func TestExtract(t *testing.T) {
+	root := parseYAML(t, extractDeployment)
+	got := Extract(root, extractEntries(t)[:1], schema.Builtin().ForKind("apps/v1", "Deployment"))
+
+	// The port omits its defaulted protocol, so only containerPort is
+	// kept as its key.
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+  finalizers:
+  - example.com/foo
+spec:
+  replicas: 3
+  selector:
+    matchLabels:
+      app: web
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:1
+        ports:
+        - containerPort: 80
+          name: http
+        resources: {}
+`, encodeYAML(t, got))
+
+	// The live object is left alone.
+	assert.Contains(t, encodeYAML(t, root), "team: payments # owned by kubectl")
+
+	// Comments are not copied.
+	got = Extract(root, extractEntries(t)[1:], nil)
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    team: payments
+spec:
+  template:
+    spec:
+      containers:
+      - name: sidecar
+        image: envoy
+`, encodeYAML(t, got))
+}
+
+func TestExtract_NoEntries(t *testing.T) {
+	root := parseYAML(t, extractDeployment)
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+`, encodeYAML(t, Extract(root, nil, nil)))
+}
@@ -0,0 +1,144 @@
+package annotate
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/timeutil"
+	"go.yaml.in/yaml/v3"
+)
+
+// Template is a user-defined annotation layout: a text/template executed
+// with the CommentData of the owners of each field, such as
+// "[{{.Manager}}]" or "{{.Manager}} {{.Op}} {{.RFC3339}}".
+type Template struct {
+	tmpl *template.Template
+}
+
+// CommentData is the data a Template is executed with: the primary owner of
+// a field, with values derived from it, and the field's other owners.
+type CommentData struct {
+	Manager     string
+	Operation   string // as in managedFields, such as "Apply"
+	Subresource string
+	APIVersion  string // API version of the managedFields entry
+	Time        time.Time
+
+	Age     string // relative time, such as "2h15m ago"
+	RFC3339 string // Time in UTC, such as "2026-02-07T12:00:00Z"
+	Op      string // lowercase Operation, such as "apply"
+
+	CoOwners []CommentData // the other managers owning the field, in owner order
+}
+
+// ParseTemplate parses a Template. It is executed with sample owners, a
+// field with a co-owner and a field with a single owner, so that references
+// to unknown fields and to co-owners a field may not have are reported here
+// rather than when annotating.
+func ParseTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("format").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format template: %w", err)
+	}
+	t := &Template{tmpl: tmpl}
+	now := time.Date(2026, 2, 7, 12, 0, 0, 0, time.UTC)
+	helm := AnnotationInfo{Manager: "helm", Operation: "Apply", APIVersion: "apps/v1", Time: now.Add(-time.Hour)}
+	kcm := AnnotationInfo{Manager: "kube-controller-manager", Operation: "Update", Subresource: "status", APIVersion: "apps/v1", Time: now.Add(-time.Minute)}
+	for _, sample := range [][]AnnotationInfo{{helm, kcm}, {helm}} {
+		if _, err := t.render(sample, now); err != nil {
+			return nil, fmt.Errorf("invalid format template: %w", err)
+		}
+	}
+	return t, nil
+}
+
+// render executes the template for a field owned by owners, the first of
+// which is the primary owner. The result is a single line.
+func (t *Template) render(owners []AnnotationInfo, now time.Time) (string, error) {
+	data := commentData(owners[0], now)
+	for _, info := range owners[1:] {
+		data.CoOwners = append(data.CoOwners, commentData(info, now))
+	}
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(sb.String()), " "), nil
+}
+
+// commentData returns the CommentData of a single owner.
+func commentData(info AnnotationInfo, now time.Time) CommentData {
+	return CommentData{
+		Manager:     info.Manager,
+		Operation:   info.Operation,
+		Subresource: info.Subresource,
+		APIVersion:  info.APIVersion,
+		Time:        info.Time,
+		Age:         timeutil.FormatRelativeTime(now, info.Time),
+		RFC3339:     info.Time.UTC().Format(time.RFC3339),
+		Op:          strings.ToLower(info.Operation),
+	}
+}
+
+// ownersComment builds the annotation of a field owned by owners, with
+// o.Format when set. When o.Format fails to execute, the error is passed to
+// o.FormatError and the built-in layout is used instead.
+func (o Options) ownersComment(owners []AnnotationInfo) string {
+	if o.Format != nil {
+		comment, err := o.Format.render(owners, o.Now)
+		if err == nil {
+			return comment
+		}
+		if o.FormatError != nil {
+			o.FormatError(err)
+		}
+	}
+	return formatOwners(owners, o.Now, o.effectiveMtime(), o.ShowOperation)
+}
+
+// renderedComment is an annotation laid out by Options.Format, with the
+// manager of the primary owner of its field.
+type renderedComment struct {
+	comment string
+	manager string
+}
+
+// renderedComments collects the annotations of an object laid out by
+// Options.Format, keyed by the annotated value node, until they can be
+// reported to Options.Rendered in the order they are written.
+type renderedComments map[*yaml.Node]renderedComment
+
+// add records the final annotation of node, owned by owners, when o reports
+// rendered annotations.
+func (r renderedComments) add(o Options, node *yaml.Node, comment string, owners []AnnotationInfo) {
+	if o.Format != nil && o.Rendered != nil {
+		r[node] = renderedComment{comment: comment, manager: owners[0].Manager}
+	}
+}
+
+// report calls o.Rendered with the annotations of the nodes under root, in
+// document order: a container before its contents.
+func (r renderedComments) report(o Options, root *yaml.Node) {
+	if len(r) == 0 {
+		return
+	}
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if c, ok := r[node]; ok {
+			o.Rendered(c.comment, c.manager)
+		}
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 1; i < len(node.Content); i += 2 {
+				walk(node.Content[i])
+			}
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				walk(item)
+			}
+		}
+	}
+	walk(root)
+}
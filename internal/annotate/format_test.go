@@ -0,0 +1,149 @@
+package annotate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplate_Invalid(t *testing.T) {
+	_, err := ParseTemplate("{{.Manager")
+	assert.ErrorContains(t, err, "invalid format template")
+
+	// Unknown fields are reported when parsing.
+	_, err = ParseTemplate("{{.Owner}}")
+	assert.ErrorContains(t, err, "can't evaluate field Owner")
+
+	// So are references to co-owners a field with a single owner lacks.
+	_, err = ParseTemplate("{{(index .CoOwners 0).Manager}}")
+	assert.ErrorContains(t, err, "index out of range")
+}
+
+func TestTemplate_Render(t *testing.T) {
+	owners := []AnnotationInfo{
+		{Manager: "kube-controller-manager", Operation: "Update", Subresource: "status", APIVersion: "apps/v1", Time: testNow.Add(-5 * time.Minute)},
+		{Manager: "helm", Operation: "Apply", APIVersion: "apps/v1", Time: testNow.Add(-2 * time.Hour)},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"[{{.Manager}}]", "[kube-controller-manager]"},
+		{"{{.Manager}} /{{.Subresource}} {{.Op}} {{.Age}}", "kube-controller-manager /status update 5m ago"},
+		{"{{.RFC3339}} {{.APIVersion}} {{.Operation}}", "2025-01-15T10:55:00Z apps/v1 Update"},
+		{"{{.Manager}}{{range .CoOwners}} + {{.Manager}} ({{.Op}})\n{{end}}", "kube-controller-manager + helm (apply)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tt.format)
+			require.NoError(t, err)
+			got, err := tmpl.render(owners, testNow)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAnnotate_Format(t *testing.T) {
+	root := parseYAML(t, "replicas: 3\nimage: nginx\n")
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "helm",
+			Time:     testNow.Add(-2 * time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:replicas":{},"f:image":{}}`),
+		},
+		{
+			Manager:  "hpa",
+			Time:     testNow.Add(-10 * time.Minute),
+			FieldsV1: buildFieldsV1(t, `{"f:replicas":{}}`),
+		},
+	}
+	tmpl, err := ParseTemplate("[{{.Manager}}{{range .CoOwners}},{{.Manager}}{{end}}]")
+	require.NoError(t, err)
+
+	var rendered [][2]string
+	Annotate(root, entries, Options{
+		Now:      testNow,
+		Format:   tmpl,
+		Rendered: func(comment, manager string) { rendered = append(rendered, [2]string{comment, manager}) },
+		Recent:   testNow.Add(-time.Hour),
+	})
+	output := encodeYAML(t, root)
+
+	// Marks are still appended.
+	assert.Contains(t, output, "replicas: 3 # [hpa,helm] [recent]\n")
+	assert.Contains(t, output, "image: nginx # [helm]\n")
+	assert.Equal(t, [][2]string{{"[hpa,helm] [recent]", "hpa"}, {"[helm]", "helm"}}, rendered)
+}
+
+func TestAnnotate_FormatError(t *testing.T) {
+	root := parseYAML(t, "replicas: 3\nimage: nginx\n")
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "helm",
+			Time:     testNow.Add(-2 * time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:replicas":{},"f:image":{}}`),
+		},
+		{
+			Manager:  "hpa",
+			Time:     testNow.Add(-10 * time.Minute),
+			FieldsV1: buildFieldsV1(t, `{"f:replicas":{}}`),
+		},
+	}
+	// Valid for the samples, but fails for the fields owned by hpa.
+	tmpl, err := ParseTemplate(`{{if eq .Manager "hpa"}}{{index .CoOwners 1}}{{end}}[{{.Manager}}]`)
+	require.NoError(t, err)
+
+	var errs []error
+	Annotate(root, entries, Options{
+		Now:         testNow,
+		Mtime:       MtimeHide,
+		Format:      tmpl,
+		FormatError: func(err error) { errs = append(errs, err) },
+	})
+	output := encodeYAML(t, root)
+
+	// The field the template fails for keeps the built-in layout.
+	assert.Equal(t, "replicas: 3 # hpa + helm\nimage: nginx # [helm]\n", output)
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "index out of range")
+}
+
+func TestAnnotate_FormatRenderedInDocumentOrder(t *testing.T) {
+	// Annotations reading the same for different managers are reported in
+	// the order they are written, inherited ones included.
+	root := parseYAML(t, "spec:\n  selector:\n    app: web\n  replicas: 3\n")
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:   "kubectl",
+			Operation: "Update",
+			Time:      testNow,
+			FieldsV1:  buildFieldsV1(t, `{"f:spec":{"f:replicas":{}}}`),
+		},
+		{
+			Manager:   "helm",
+			Operation: "Update",
+			Time:      testNow,
+			FieldsV1:  buildFieldsV1(t, `{"f:spec":{"f:selector":{}}}`),
+		},
+	}
+	tmpl, err := ParseTemplate("{{.Op}}")
+	require.NoError(t, err)
+
+	var rendered [][2]string
+	Annotate(root, entries, Options{
+		Now:      testNow,
+		Format:   tmpl,
+		Rendered: func(comment, manager string) { rendered = append(rendered, [2]string{comment, manager}) },
+		Inherit:  true,
+	})
+
+	assert.Equal(t, [][2]string{
+		{"update", "helm"},
+		{"update [inherited]", "helm"},
+		{"update", "kubectl"},
+	}, rendered)
+}
@@ -0,0 +1,120 @@
+package annotate
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"go.yaml.in/yaml/v3"
+)
+
+// Ghost is a stale ownership claim: a FieldsV1 leaf of a managedFields entry
+// that matches nothing in the object, typically because the field was
+// removed after the manager last wrote it. Each unresolved leaf below the
+// element that failed to match is a Ghost of its own.
+type Ghost struct {
+	Owner   AnnotationInfo
+	Path    string // path of the deepest node that exists, e.g. ".spec"
+	Element string // FieldsV1 element that matched nothing, e.g. "f:template"
+	Leaf    string // FieldsV1 path of the leaf from Element, e.g. "f:template.f:spec.f:volumes"
+
+	node      *yaml.Node // node the element was looked up in
+	parentKey *yaml.Node // key of node in its parent mapping, if any
+}
+
+// String describes the ghost, e.g. `f:replicas at .spec` or, below the
+// element that failed to match, `f:template.f:spec at .spec (f:template
+// missing)`.
+func (g Ghost) String() string {
+	s := g.Leaf + " at " + displayPath(g.Path)
+	if g.Leaf != g.Element {
+		s += " (" + g.Element + " missing)"
+	}
+	return s
+}
+
+// fieldsV1Leaves returns the FieldsV1 paths of the leaves of fields, the
+// subtree under element, in FieldsV1 order. A "." marker stands for element
+// itself.
+func fieldsV1Leaves(element string, fields *yaml.Node) []string {
+	if fields == nil || fields.Kind != yaml.MappingNode || isLeaf(fields) {
+		return []string{element}
+	}
+	var leaves []string
+	for i := 0; i < len(fields.Content)-1; i += 2 {
+		key := fields.Content[i].Value
+		if key == "." {
+			leaves = append(leaves, element)
+			continue
+		}
+		leaves = append(leaves, fieldsV1Leaves(element+"."+key, fields.Content[i+1])...)
+	}
+	return leaves
+}
+
+// FindGhosts returns the stale ownership claims of entries against the
+// resource root MappingNode, one per unresolved FieldsV1 leaf, in entry order
+// and, within an entry, in FieldsV1 order. The schema of the object, s, may be nil (see Options.Schema).
+func FindGhosts(root *yaml.Node, entries []managed.ManagedFieldsEntry, s *schema.Schema) []Ghost {
+	paths := fieldPaths(root)
+	w := &walkOptions{}
+	if s != nil {
+		w.keyDefaults = keyDefaults(nodeSchemas(root, s))
+	}
+	var ghosts []Ghost
+	for _, entry := range entries {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		info := annotationFrom(entry)
+		w.unresolved = func(node, parentKey *yaml.Node, key string, fields *yaml.Node) {
+			for _, leaf := range fieldsV1Leaves(key, fields) {
+				ghosts = append(ghosts, Ghost{
+					Owner:     info,
+					Path:      paths[node],
+					Element:   key,
+					Leaf:      leaf,
+					node:      node,
+					parentKey: parentKey,
+				})
+			}
+		}
+		walkFieldsV1(root, nil, entry.FieldsV1, entry, make(map[*yaml.Node]AnnotationTarget), w)
+	}
+	return ghosts
+}
+
+// InjectGhosts adds a "manager (ghost: f:field)" comment naming the leaf of
+// each ghost where its element would be: above the first field or item of the node it was
+// looked up in or, when that node is empty, above its key. The ghost
+// comments go before any existing comment of that node (such as an
+// annotation in above mode), so InjectGhosts may run after Annotate.
+func InjectGhosts(ghosts []Ghost) {
+	var targets []*yaml.Node
+	lines := make(map[*yaml.Node][]string)
+	for _, g := range ghosts {
+		var target *yaml.Node
+		switch {
+		case len(g.node.Content) > 0 && g.node.Kind != yaml.ScalarNode:
+			target = g.node.Content[0]
+		case g.parentKey != nil:
+			target = g.parentKey
+		default:
+			target = g.node
+		}
+		if _, ok := lines[target]; !ok {
+			targets = append(targets, target)
+		}
+		comment := formatComment(g.Owner, time.Time{}, MtimeHide, false) + " (ghost: " + g.Leaf + ")"
+		lines[target] = append(lines[target], comment)
+	}
+
+	for _, target := range targets {
+		comment := strings.Join(lines[target], "\n")
+		if target.HeadComment != "" {
+			comment += "\n" + target.HeadComment
+		}
+		target.HeadComment = comment
+	}
+}
@@ -0,0 +1,118 @@
+package annotate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ghostEntries(t *testing.T) []managed.ManagedFieldsEntry {
+	t.Helper()
+	return []managed.ManagedFieldsEntry{
+		{
+			Manager:  "helm",
+			Time:     testNow.Add(-2 * time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:spec":{"f:replicas":{},"f:paused":{},"f:ports":{"k:{\"port\":80}":{"f:name":{}},"k:{\"port\":81}":{"f:name":{}}}}}`),
+		},
+		{
+			Manager:     "kube-controller-manager",
+			Subresource: "status",
+			Time:        testNow.Add(-time.Hour),
+			FieldsV1:    buildFieldsV1(t, `{"f:status":{"f:replicas":{}},"f:spec":{"f:finalizers":{"v:\"a\"":{}},"f:args":{"i:0":{}}}}`),
+		},
+	}
+}
+
+const ghostYAML = `spec:
+  replicas: 3
+  ports:
+  - port: 80
+    name: http
+  finalizers: []
+  args: []
+`
+
+func TestFindGhosts(t *testing.T) {
+	root := parseYAML(t, ghostYAML)
+
+	ghosts := FindGhosts(root, ghostEntries(t), nil)
+
+	var got []string
+	for _, g := range ghosts {
+		got = append(got, g.Owner.Manager+": "+g.String())
+	}
+	assert.Equal(t, []string{
+		"helm: f:paused at .spec",
+		`helm: k:{"port":81}.f:name at .spec.ports (k:{"port":81} missing)`,
+		"kube-controller-manager: f:status.f:replicas at . (f:status missing)",
+		`kube-controller-manager: v:"a" at .spec.finalizers`,
+		"kube-controller-manager: i:0 at .spec.args",
+	}, got)
+}
+
+func TestFindGhosts_NestedLeaves(t *testing.T) {
+	root := parseYAML(t, "spec:\n  replicas: 3\n")
+	entries := []managed.ManagedFieldsEntry{
+		{
+			Manager:  "helm",
+			FieldsV1: buildFieldsV1(t, `{"f:spec":{"f:replicas":{},"f:template":{"f:metadata":{"f:labels":{"f:app":{}}},"f:spec":{"f:containers":{"k:{\"name\":\"app\"}":{".":{},"f:image":{},"f:name":{}}}}}}}`),
+		},
+	}
+
+	var got []string
+	for _, g := range FindGhosts(root, entries, nil) {
+		assert.Equal(t, "f:template", g.Element)
+		got = append(got, g.String())
+	}
+	assert.Equal(t, []string{
+		"f:template.f:metadata.f:labels.f:app at .spec (f:template missing)",
+		`f:template.f:spec.f:containers.k:{"name":"app"} at .spec (f:template missing)`,
+		`f:template.f:spec.f:containers.k:{"name":"app"}.f:image at .spec (f:template missing)`,
+		`f:template.f:spec.f:containers.k:{"name":"app"}.f:name at .spec (f:template missing)`,
+	}, got)
+}
+
+func TestFindGhosts_NoneWhenAllResolved(t *testing.T) {
+	root := parseYAML(t, "replicas: 3\n")
+	entries := []managed.ManagedFieldsEntry{
+		{Manager: "helm", FieldsV1: buildFieldsV1(t, `{"f:replicas":{}}`)},
+	}
+	assert.Empty(t, FindGhosts(root, entries, nil))
+}
+
+func TestInjectGhosts_Inline(t *testing.T) {
+	root := parseYAML(t, ghostYAML)
+	entries := ghostEntries(t)
+
+	Annotate(root, entries, Options{Now: testNow, Mtime: MtimeHide})
+	InjectGhosts(FindGhosts(root, entries, nil))
+	output := encodeYAML(t, root)
+
+	assert.Equal(t, `# kube-controller-manager /status (ghost: f:status.f:replicas)
+spec:
+  # helm (ghost: f:paused)
+  replicas: 3 # helm
+  ports:
+  # helm (ghost: k:{"port":81}.f:name)
+  - port: 80
+    name: http # helm
+  # kube-controller-manager /status (ghost: v:"a")
+  finalizers: []
+  # kube-controller-manager /status (ghost: i:0)
+  args: []
+`, output)
+}
+
+func TestInjectGhosts_BeforeAboveAnnotations(t *testing.T) {
+	root := parseYAML(t, ghostYAML)
+	entries := ghostEntries(t)
+
+	Annotate(root, entries, Options{Now: testNow, Mtime: MtimeHide, Above: true})
+	InjectGhosts(FindGhosts(root, entries, nil))
+	output := encodeYAML(t, root)
+
+	require.Contains(t, output, "  # helm (ghost: f:paused)\n  # helm\n  replicas: 3\n")
+}
@@ -0,0 +1,46 @@
+package annotate
+
+import "go.yaml.in/yaml/v3"
+
+// InheritedMark is appended to the annotation of fields whose ownership is
+// inherited from an atomically owned container (see Options.Inherit).
+const InheritedMark = " [inherited]"
+
+// inheritOwnership annotates the descendants of atomically owned containers
+// that have no owner of their own with the owners of the nearest atomically
+// owned ancestor, marked with InheritedMark and followed by their semantic
+// label, if any. A container owned through a "." marker passes nothing down,
+// as its owner only claims that it exists. metadata.managedFields is skipped.
+// Annotations laid out by opts.Format are added to rendered.
+func inheritOwnership(root *yaml.Node, targets map[*yaml.Node]AnnotationTarget, labels map[*yaml.Node]semanticLabel, rendered renderedComments, opts Options) {
+	_, metadata := findMappingField(root, "metadata")
+	order := opts.effectiveOwnerOrder()
+
+	var walk func(key, node *yaml.Node, inherited []AnnotationInfo)
+	walk = func(key, node *yaml.Node, inherited []AnnotationInfo) {
+		if target, ok := targets[node]; ok {
+			if target.Atomic {
+				inherited = orderOwners(target.Info, target.CoOwners, order)
+			}
+		} else if inherited != nil {
+			comment := opts.ownersComment(inherited) + InheritedMark + labelMark(labels, node)
+			injectComment(AnnotationTarget{KeyNode: key, ValueNode: node}, comment, opts.Above)
+			rendered.add(opts, node, comment, inherited)
+		}
+
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i < len(node.Content)-1; i += 2 {
+				if node == metadata && node.Content[i].Value == "managedFields" {
+					continue
+				}
+				walk(node.Content[i], node.Content[i+1], inherited)
+			}
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				walk(nil, item, inherited)
+			}
+		}
+	}
+	walk(nil, root, nil)
+}
@@ -0,0 +1,53 @@
+package annotate
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// OwnerOrder controls the order in which the managers co-owning a field are
+// listed. The first manager is the field's primary owner, used for coloring
+// and for change marks.
+type OwnerOrder string
+
+const (
+	// OrderTime lists the most recently updated manager first.
+	OrderTime OwnerOrder = "time"
+
+	// OrderApply lists Apply managers before Update managers, each group
+	// most recently updated first.
+	OrderApply OwnerOrder = "apply"
+)
+
+// coOwnerSeparator joins the annotations of the managers co-owning a field.
+const coOwnerSeparator = " + "
+
+// orderOwners returns info and coOwners sorted by order. Ties keep the order
+// of the managedFields entries, so the result is stable.
+func orderOwners(info AnnotationInfo, coOwners []AnnotationInfo, order OwnerOrder) []AnnotationInfo {
+	owners := append([]AnnotationInfo{info}, coOwners...)
+	sort.SliceStable(owners, func(i, j int) bool {
+		a, b := owners[i], owners[j]
+		if order == OrderApply && isApply(a) != isApply(b) {
+			return isApply(a)
+		}
+		return a.Time.After(b.Time)
+	})
+	return owners
+}
+
+// isApply reports whether the owner wrote the field with server-side apply.
+func isApply(info AnnotationInfo) bool {
+	return info.Operation == "Apply"
+}
+
+// formatOwners builds the annotation string for a field owned by one or more
+// managers, e.g. "helm (2h ago) + kubectl (5m ago)".
+func formatOwners(owners []AnnotationInfo, now time.Time, mtime MtimeMode, showOperation bool) string {
+	parts := make([]string, len(owners))
+	for i, info := range owners {
+		parts[i] = formatComment(info, now, mtime, showOperation)
+	}
+	return strings.Join(parts, coOwnerSeparator)
+}
@@ -0,0 +1,173 @@
+package annotate
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"go.yaml.in/yaml/v3"
+)
+
+// PrunedMark is appended to the annotation of fields that would be deleted
+// when their manager applies a new manifest (see Options.Pruned).
+const PrunedMark = " [pruned]"
+
+// FindPrunes predicts the fields of the live root MappingNode that server-side
+// apply would delete when manager applies the manifest root MappingNode: the
+// fields of manager's Apply entry that the manifest no longer sets, unless
+// another managedFields entry owns them or a field inside them. The key
+// fields of a list item are only pruned with the item. It returns
+// their paths in the live object, in document order and topmost first; the
+// fields inside a pruned container are pruned too but not listed.
+//
+// List items are matched between the live object and the manifest by their
+// FieldsV1 key, with the key defaults from the schema s of the object (which
+// may be nil).
+func FindPrunes(manifest, live *yaml.Node, entries []managed.ManagedFieldsEntry, manager string, s *schema.Schema) []string {
+	liveDefaults := &walkOptions{}
+	manifestDefaults := &walkOptions{}
+	if s != nil {
+		liveDefaults.keyDefaults = keyDefaults(nodeSchemas(live, s))
+		manifestDefaults.keyDefaults = keyDefaults(nodeSchemas(manifest, s))
+	}
+
+	// Fields of manager's Apply entry that the manifest no longer sets, and
+	// fields owned by others.
+	removed := make(map[*yaml.Node]AnnotationTarget)
+	others := make(map[*yaml.Node]AnnotationTarget)
+	keys := make(map[*yaml.Node]bool)
+	for _, entry := range entries {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		if entry.Manager == manager && entry.Operation == "Apply" && entry.Subresource == "" {
+			p := pruneWalker{entry: entry, removed: removed, keys: keys, live: liveDefaults, manifest: manifestDefaults}
+			p.walk(entry.FieldsV1, live, manifest)
+		} else {
+			walkFieldsV1(live, nil, entry.FieldsV1, entry, others, liveDefaults)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	// keptByOthers reports whether others own node or anything inside it.
+	var keptByOthers func(node *yaml.Node) bool
+	keptByOthers = func(node *yaml.Node) bool {
+		if _, ok := others[node]; ok {
+			return true
+		}
+		return slices.ContainsFunc(childNodes(node), keptByOthers)
+	}
+
+	paths := fieldPaths(live)
+	var pruned []string
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if _, ok := removed[node]; ok && !keys[node] && !keptByOthers(node) {
+			pruned = append(pruned, paths[node])
+			return
+		}
+		for _, child := range childNodes(node) {
+			walk(child)
+		}
+	}
+	walk(live)
+	return pruned
+}
+
+// isPruned reports whether the field at path is one of pruned or inside one
+// of them.
+func isPruned(pruned []string, path string) bool {
+	for _, p := range pruned {
+		if path == p || strings.HasPrefix(path, p) && (path[len(p)] == '.' || path[len(p)] == '[') {
+			return true
+		}
+	}
+	return false
+}
+
+// childNodes returns the values of a MappingNode or the items of a
+// SequenceNode.
+func childNodes(node *yaml.Node) []*yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		var values []*yaml.Node
+		for i := 1; i < len(node.Content); i += 2 {
+			values = append(values, node.Content[i])
+		}
+		return values
+	case yaml.SequenceNode:
+		return node.Content
+	default:
+		return nil
+	}
+}
+
+// pruneWalker walks the FieldsV1 of an Apply entry in parallel with the live
+// object and the new manifest, collecting the live fields the manifest no
+// longer sets.
+type pruneWalker struct {
+	entry          managed.ManagedFieldsEntry
+	removed        map[*yaml.Node]AnnotationTarget
+	keys           map[*yaml.Node]bool // key fields of the live list items
+	live, manifest *walkOptions        // key defaults of the two trees
+}
+
+// walk compares the FieldsV1 elements of fields against the live node and
+// the corresponding manifest node (nil when the manifest has none).
+func (p pruneWalker) walk(fields, live, manifest *yaml.Node) {
+	for i := 0; i < len(fields.Content)-1; i += 2 {
+		key := fields.Content[i].Value
+		val := fields.Content[i+1]
+		prefix, content := managed.ParseFieldsV1Key(key)
+
+		var liveKey, liveNode, manifestNode *yaml.Node
+		switch prefix {
+		case "f":
+			liveKey, liveNode = findMappingField(live, content)
+			_, manifestNode = findMappingField(manifest, content)
+		case "k":
+			assocKey, err := managed.ParseAssociativeKey(content)
+			if err != nil {
+				continue
+			}
+			liveNode = findSequenceItemByKey(live, assocKey, p.live.defaults(live))
+			for field := range assocKey {
+				if _, v := findMappingField(liveNode, field); v != nil {
+					p.keys[v] = true
+				}
+			}
+			manifestNode = findSequenceItemByKey(manifest, assocKey, p.manifest.defaults(manifest))
+		case "v":
+			liveNode = findSequenceItemByValue(live, content)
+			manifestNode = findSequenceItemByValue(manifest, content)
+		case "i":
+			index, err := managed.ParseIndex(content)
+			if err != nil {
+				continue
+			}
+			liveNode = findSequenceItemByIndex(live, index)
+			manifestNode = findSequenceItemByIndex(manifest, index)
+		default:
+			continue
+		}
+
+		switch {
+		case liveNode == nil:
+			// A ghost: nothing to delete.
+		case manifestNode == nil && isLeaf(val):
+			addTarget(p.removed, liveKey, liveNode, annotationFrom(p.entry), true)
+		case manifestNode == nil:
+			// The manifest drops the container: everything the entry
+			// owns in it goes.
+			walkFieldsV1(liveNode, liveKey, val, p.entry, p.removed, p.live)
+			if _, ok := p.removed[liveNode]; !ok {
+				addTarget(p.removed, liveKey, liveNode, annotationFrom(p.entry), false)
+			}
+		case !isLeaf(val):
+			p.walk(val, liveNode, manifestNode)
+		}
+	}
+}
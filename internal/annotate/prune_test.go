@@ -0,0 +1,110 @@
+package annotate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+const prunePod = `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  labels:
+    app: web
+    tier: frontend
+spec:
+  containers:
+  - name: web
+    image: web:1
+    env:
+    - name: A
+      value: "1"
+    - name: B
+      value: "2"
+    - name: C
+      value: "3"
+    ports:
+    - containerPort: 80
+`
+
+func pruneEntries(t *testing.T) []managed.ManagedFieldsEntry {
+	t.Helper()
+	return []managed.ManagedFieldsEntry{
+		{
+			Manager:   "argocd",
+			Operation: "Apply",
+			Time:      testNow.Add(-2 * time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:metadata":{"f:labels":{"f:app":{},"f:tier":{}}},"f:spec":{"f:containers":{"k:{\"name\":\"web\"}":{".":{},"f:name":{},"f:image":{},`+
+				`"f:env":{"k:{\"name\":\"A\"}":{".":{},"f:name":{},"f:value":{}},"k:{\"name\":\"B\"}":{".":{},"f:name":{},"f:value":{}},"k:{\"name\":\"C\"}":{".":{},"f:name":{},"f:value":{}}},`+
+				`"f:ports":{"k:{\"containerPort\":80,\"protocol\":\"TCP\"}":{".":{},"f:containerPort":{}}}}}}}`),
+		},
+		{
+			Manager:   "injector",
+			Operation: "Update",
+			Time:      testNow.Add(-time.Hour),
+			FieldsV1:  buildFieldsV1(t, `{"f:spec":{"f:containers":{"k:{\"name\":\"web\"}":{"f:env":{"k:{\"name\":\"C\"}":{"f:value":{}}}}}}}`),
+		},
+	}
+}
+
+func TestFindPrunes(t *testing.T) {
+	live := parseYAML(t, prunePod)
+	// The new manifest drops env B and C, the tier label and the ports
+	// (whose protocol the live object omits, matched by its default).
+	manifest := parseYAML(t, `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  containers:
+  - name: web
+    image: web:2
+    env:
+    - name: A
+      value: "1"
+`)
+	pruned := FindPrunes(manifest, live, pruneEntries(t), "argocd", schema.Builtin().ForKind("v1", "Pod"))
+
+	// C stays: injector owns its value.
+	assert.Equal(t, []string{
+		".metadata.labels.tier",
+		".spec.containers[0].env[1]",
+		".spec.containers[0].ports",
+	}, pruned)
+}
+
+func TestFindPrunes_NoApplyEntry(t *testing.T) {
+	live := parseYAML(t, prunePod)
+	manifest := parseYAML(t, "kind: Pod\n")
+	assert.Empty(t, FindPrunes(manifest, live, pruneEntries(t), "helm", nil))
+}
+
+func TestAnnotate_MarksPruned(t *testing.T) {
+	root := parseYAML(t, prunePod)
+	Annotate(root, pruneEntries(t), Options{
+		Now:    testNow,
+		Mtime:  MtimeHide,
+		Pruned: []string{".metadata.labels.tier", ".spec.containers[0].env[1]"},
+	})
+	output := encodeYAML(t, root)
+
+	assert.Contains(t, output, "tier: frontend # argocd [pruned]\n")
+	assert.Contains(t, output, "    - # argocd [pruned]\n      name: B # argocd [pruned]\n      value: \"2\" # argocd [pruned]\n")
+	assert.Contains(t, output, "app: web # argocd\n")
+	assert.Contains(t, output, "value: \"3\" # injector + argocd\n")
+}
+
+func TestIsPruned(t *testing.T) {
+	pruned := []string{".spec.env[1]", ".metadata.labels.tier"}
+	assert.True(t, isPruned(pruned, ".spec.env[1]"))
+	assert.True(t, isPruned(pruned, ".spec.env[1].name"))
+	assert.False(t, isPruned(pruned, ".spec.env[10]"))
+	assert.False(t, isPruned(pruned, ".metadata.labels.tiers"))
+	assert.False(t, isPruned(nil, ".spec"))
+}
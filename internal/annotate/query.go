@@ -0,0 +1,319 @@
+package annotate
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"go.yaml.in/yaml/v3"
+)
+
+// Path is a parsed field path expression, such as
+// "spec.template.spec.containers[name=app].image". Elements are:
+//
+//	.name or name   a mapping field (["name"] when it contains "." or "[")
+//	[2]             the list item at an index
+//	[k=v,k2=v2]     the list item whose fields k and k2 have these values
+//	[=v]            the item of a list of scalars (a set) with value v
+//
+// Values may be double-quoted. A leading "." is optional; "." alone is the
+// object itself.
+type Path struct {
+	expr     string
+	elements []pathElement
+}
+
+// pathElementKind is the kind of a Path element.
+type pathElementKind int
+
+const (
+	elemField pathElementKind = iota
+	elemIndex
+	elemKeys
+	elemValue
+)
+
+// pathElement is one step of a Path.
+type pathElement struct {
+	kind  pathElementKind
+	name  string      // elemField: field name; elemValue: item value
+	index int         // elemIndex
+	keys  [][2]string // elemKeys: field name and value pairs
+}
+
+// ParsePath parses a field path expression (see Path).
+func ParsePath(expr string) (Path, error) {
+	p := Path{expr: expr}
+	rest := strings.TrimPrefix(expr, ".")
+	for first := true; rest != ""; first = false {
+		switch {
+		case rest[0] == '[':
+			end := closingBracket(rest)
+			if end < 0 {
+				return Path{}, fmt.Errorf("invalid path %q: unterminated [", expr)
+			}
+			elem, err := parseBracket(rest[1:end])
+			if err != nil {
+				return Path{}, fmt.Errorf("invalid path %q: %w", expr, err)
+			}
+			p.elements = append(p.elements, elem)
+			rest = rest[end+1:]
+		case rest[0] == '.' || first:
+			if !first {
+				rest = rest[1:]
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return Path{}, fmt.Errorf("invalid path %q: empty field name", expr)
+			}
+			p.elements = append(p.elements, pathElement{kind: elemField, name: rest[:end]})
+			rest = rest[end:]
+		default:
+			return Path{}, fmt.Errorf("invalid path %q: unexpected %q", expr, rest[:1])
+		}
+	}
+	return p, nil
+}
+
+// closingBracket returns the index of the "]" closing the "[" that s starts
+// with, skipping quoted strings, or -1.
+func closingBracket(s string) int {
+	quoted := false
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseBracket parses the contents of a [...] Path element.
+func parseBracket(s string) (pathElement, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		name, err := strconv.Unquote(s)
+		if err != nil {
+			return pathElement{}, fmt.Errorf("invalid field name %s", s)
+		}
+		return pathElement{kind: elemField, name: name}, nil
+	case strings.HasPrefix(s, "="):
+		value, err := unquotePathValue(s[1:])
+		if err != nil {
+			return pathElement{}, err
+		}
+		return pathElement{kind: elemValue, name: value}, nil
+	case strings.Contains(s, "="):
+		var keys [][2]string
+		for _, pair := range splitPathKeys(s) {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok || k == "" {
+				return pathElement{}, fmt.Errorf("invalid list item selector [%s]: want [key=value,...]", s)
+			}
+			value, err := unquotePathValue(v)
+			if err != nil {
+				return pathElement{}, err
+			}
+			keys = append(keys, [2]string{k, value})
+		}
+		return pathElement{kind: elemKeys, keys: keys}, nil
+	default:
+		index, err := strconv.Atoi(s)
+		if err != nil || index < 0 {
+			return pathElement{}, fmt.Errorf("invalid list index [%s]", s)
+		}
+		return pathElement{kind: elemIndex, index: index}, nil
+	}
+}
+
+// splitPathKeys splits the key=value pairs of a list item selector at the
+// commas outside quoted values.
+func splitPathKeys(s string) []string {
+	var pairs []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == ',':
+			pairs = append(pairs, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(pairs, s[start:])
+}
+
+// unquotePathValue returns a value of a Path element, unquoting it when it
+// is double-quoted.
+func unquotePathValue(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+	value, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted value %s", s)
+	}
+	return value, nil
+}
+
+// String returns the expression the path was parsed from.
+func (p Path) String() string {
+	return p.expr
+}
+
+// FieldOwners is the ownership of the field a Path resolves to.
+type FieldOwners struct {
+	Path  Path
+	Found bool   // whether the path resolves to a field of the object
+	Field string // path of the field in the object, such as ".spec.containers[1].image"
+
+	// Owners are the managers owning the field, ordered by
+	// Options.OwnerOrder, or none when the field is unowned.
+	Owners []AnnotationInfo
+
+	// Inherited is set when the field has no owner of its own and Owners
+	// own an atomic container of it, such as a label selector.
+	Inherited bool
+}
+
+// Query resolves each path against the root MappingNode and returns the
+// owners of the fields they address according to entries. List items
+// selected by key match items that omit a key field defaulted by
+// opts.Schema, as with k: elements.
+func Query(root *yaml.Node, entries []managed.ManagedFieldsEntry, paths []Path, opts Options) []FieldOwners {
+	var schemas map[*yaml.Node]*schema.Schema
+	w := &walkOptions{}
+	if opts.Schema != nil {
+		schemas = nodeSchemas(root, opts.Schema)
+		w.keyDefaults = keyDefaults(schemas)
+	}
+	targets := make(map[*yaml.Node]AnnotationTarget)
+	for _, entry := range entries {
+		if entry.FieldsV1 != nil {
+			walkFieldsV1(root, nil, entry.FieldsV1, entry, targets, w)
+		}
+	}
+
+	results := make([]FieldOwners, len(paths))
+	for i, p := range paths {
+		results[i] = FieldOwners{Path: p}
+		nodes, field := p.resolve(root, w)
+		if nodes == nil {
+			continue
+		}
+		results[i].Found = true
+		results[i].Field = displayPath(field)
+
+		// The field's own owners, or those of its nearest atomic
+		// container.
+		for j := len(nodes) - 1; j >= 0; j-- {
+			target, ok := targets[nodes[j]]
+			if !ok || j < len(nodes)-1 && !target.Atomic {
+				continue
+			}
+			results[i].Owners = orderOwners(target.Info, target.CoOwners, opts.effectiveOwnerOrder())
+			results[i].Inherited = j < len(nodes)-1
+			break
+		}
+	}
+	return results
+}
+
+// resolve returns the nodes from root to the field p addresses, and the
+// field's path as rendered by fieldPaths, or nil when the object has no such
+// field.
+func (p Path) resolve(root *yaml.Node, w *walkOptions) ([]*yaml.Node, string) {
+	nodes := []*yaml.Node{root}
+	node, field := root, ""
+	for _, elem := range p.elements {
+		var next *yaml.Node
+		switch elem.kind {
+		case elemField:
+			_, next = findMappingField(node, elem.name)
+			field += pathField(elem.name)
+		case elemIndex:
+			next = findSequenceItemByIndex(node, elem.index)
+		case elemKeys:
+			next = findSequenceItem(node, func(item *yaml.Node) bool {
+				return matchesPathKeys(item, elem.keys, w.defaults(node))
+			})
+		case elemValue:
+			next = findSequenceItem(node, func(item *yaml.Node) bool {
+				return matchesPathValue(item, elem.name)
+			})
+		}
+		if next == nil {
+			return nil, ""
+		}
+		if elem.kind != elemField {
+			field += "[" + strconv.Itoa(slices.Index(node.Content, next)) + "]"
+		}
+		nodes = append(nodes, next)
+		node = next
+	}
+	return nodes, field
+}
+
+// findSequenceItem returns the first item of a SequenceNode for which match
+// returns true, or nil.
+func findSequenceItem(seq *yaml.Node, match func(item *yaml.Node) bool) *yaml.Node {
+	if seq.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, item := range seq.Content {
+		if match(item) {
+			return item
+		}
+	}
+	return nil
+}
+
+// matchesPathKeys reports whether a list item has the given field values. A
+// field the item omits matches its default, if any.
+func matchesPathKeys(item *yaml.Node, keys [][2]string, defaults map[string]any) bool {
+	if item.Kind != yaml.MappingNode {
+		return false
+	}
+	for _, kv := range keys {
+		_, value := findMappingField(item, kv[0])
+		if value == nil {
+			if d, ok := defaults[kv[0]]; !ok || fmt.Sprint(d) != kv[1] {
+				return false
+			}
+			continue
+		}
+		if !matchesPathValue(value, kv[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesPathValue reports whether a scalar node has the value text, written
+// as in YAML: numbers match in any notation (so 0x50 matches 80).
+func matchesPathValue(node *yaml.Node, text string) bool {
+	if node.Kind != yaml.ScalarNode {
+		return false
+	}
+	if node.Value == text {
+		return true
+	}
+	n, ok := scalarNumber(node)
+	if !ok {
+		return false
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	return err == nil && v == n
+}
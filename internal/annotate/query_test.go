@@ -0,0 +1,127 @@
+package annotate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []pathElement
+	}{
+		{".", nil},
+		{"spec.replicas", []pathElement{{kind: elemField, name: "spec"}, {kind: elemField, name: "replicas"}}},
+		{".spec.replicas", []pathElement{{kind: elemField, name: "spec"}, {kind: elemField, name: "replicas"}}},
+		{`metadata.labels["app.kubernetes.io/name"]`, []pathElement{{kind: elemField, name: "metadata"}, {kind: elemField, name: "labels"}, {kind: elemField, name: "app.kubernetes.io/name"}}},
+		{"containers[1]", []pathElement{{kind: elemField, name: "containers"}, {kind: elemIndex, index: 1}}},
+		{`ports[containerPort=80,protocol="TCP"]`, []pathElement{{kind: elemField, name: "ports"}, {kind: elemKeys, keys: [][2]string{{"containerPort", "80"}, {"protocol", "TCP"}}}}},
+		{`env[name="a,b]"].value`, []pathElement{{kind: elemField, name: "env"}, {kind: elemKeys, keys: [][2]string{{"name", "a,b]"}}}, {kind: elemField, name: "value"}}},
+		{"finalizers[=example.com/foo]", []pathElement{{kind: elemField, name: "finalizers"}, {kind: elemValue, name: "example.com/foo"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := ParsePath(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p.elements)
+			assert.Equal(t, tt.expr, p.String())
+		})
+	}
+}
+
+func TestParsePath_Invalid(t *testing.T) {
+	for _, expr := range []string{"spec..replicas", "containers[", "containers[-1]", "containers[x]", "env[=a,=b]x", "env[name]", `env[name="a]`, "a[0]b"} {
+		_, err := ParsePath(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+const queryPod = `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  finalizers:
+  - example.com/foo
+spec:
+  selector:
+    matchLabels:
+      app: web
+  containers:
+  - name: sidecar
+    image: envoy
+  - name: web
+    image: web:1
+    ports:
+    - containerPort: 80
+`
+
+func queryEntries(t *testing.T) []managed.ManagedFieldsEntry {
+	t.Helper()
+	return []managed.ManagedFieldsEntry{
+		{
+			Manager:   "helm",
+			Operation: "Apply",
+			Time:      testNow.Add(-2 * time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:spec":{"f:selector":{},"f:containers":{"k:{\"name\":\"web\"}":{".":{},"f:name":{},"f:image":{},`+
+				`"f:ports":{"k:{\"containerPort\":80,\"protocol\":\"TCP\"}":{".":{},"f:containerPort":{}}}}}}}`),
+		},
+		{
+			Manager:   "kubectl",
+			Operation: "Update",
+			Time:      testNow.Add(-time.Hour),
+			FieldsV1:  buildFieldsV1(t, `{"f:metadata":{"f:finalizers":{"v:\"example.com/foo\"":{}}},"f:spec":{"f:containers":{"k:{\"name\":\"web\"}":{"f:image":{}}}}}`),
+		},
+	}
+}
+
+func TestQuery(t *testing.T) {
+	root := parseYAML(t, queryPod)
+	var paths []Path
+	for _, expr := range []string{
+		"spec.containers[name=web].image",
+		"spec.containers[1].ports[containerPort=80,protocol=TCP].containerPort",
+		"metadata.finalizers[=example.com/foo]",
+		"spec.selector.matchLabels.app",
+		"spec.containers[name=sidecar].image",
+		"spec.containers[name=db]",
+	} {
+		p, err := ParsePath(expr)
+		require.NoError(t, err)
+		paths = append(paths, p)
+	}
+	entries := queryEntries(t)
+	helm, kubectl := annotationFrom(entries[0]), annotationFrom(entries[1])
+
+	got := Query(root, entries, paths, Options{Schema: schema.Builtin().ForKind("v1", "Pod")})
+	require.Len(t, got, 6)
+
+	assert.Equal(t, FieldOwners{Path: paths[0], Found: true, Field: ".spec.containers[1].image", Owners: []AnnotationInfo{kubectl, helm}}, got[0])
+	assert.Equal(t, FieldOwners{Path: paths[1], Found: true, Field: ".spec.containers[1].ports[0].containerPort", Owners: []AnnotationInfo{helm}}, got[1])
+	assert.Equal(t, FieldOwners{Path: paths[2], Found: true, Field: ".metadata.finalizers[0]", Owners: []AnnotationInfo{kubectl}}, got[2])
+	assert.Equal(t, FieldOwners{Path: paths[3], Found: true, Field: ".spec.selector.matchLabels.app", Owners: []AnnotationInfo{helm}, Inherited: true}, got[3])
+	assert.Equal(t, FieldOwners{Path: paths[4], Found: true, Field: ".spec.containers[0].image"}, got[4])
+	assert.Equal(t, FieldOwners{Path: paths[5]}, got[5])
+}
+
+func TestQuery_OwnerOrder(t *testing.T) {
+	root := parseYAML(t, queryPod)
+	p, err := ParsePath("spec.containers[name=web].image")
+	require.NoError(t, err)
+	entries := queryEntries(t)
+
+	got := Query(root, entries, []Path{p}, Options{OwnerOrder: OrderApply})
+	assert.Equal(t, []AnnotationInfo{annotationFrom(entries[0]), annotationFrom(entries[1])}, got[0].Owners)
+}
+
+func TestQuery_DefaultedKey(t *testing.T) {
+	// Without the schema, protocol=TCP does not match a port omitting it.
+	root := parseYAML(t, queryPod)
+	p, err := ParsePath("spec.containers[name=web].ports[containerPort=80,protocol=TCP]")
+	require.NoError(t, err)
+	assert.False(t, Query(root, queryEntries(t), []Path{p}, Options{})[0].Found)
+}
@@ -0,0 +1,103 @@
+package annotate
+
+import (
+	"strings"
+
+	"github.com/ahmetb/kubectl-fields/internal/schema"
+	"go.yaml.in/yaml/v3"
+)
+
+// nodeSchemas maps every node under root that s describes to its schema.
+func nodeSchemas(root *yaml.Node, s *schema.Schema) map[*yaml.Node]*schema.Schema {
+	schemas := make(map[*yaml.Node]*schema.Schema)
+	var walk func(node *yaml.Node, s *schema.Schema)
+	walk = func(node *yaml.Node, s *schema.Schema) {
+		if s == nil {
+			return
+		}
+		schemas[node] = s
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i < len(node.Content)-1; i += 2 {
+				walk(node.Content[i+1], s.Field(node.Content[i].Value))
+			}
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				walk(item, s.Item())
+			}
+		}
+	}
+	walk(root, s)
+	return schemas
+}
+
+// keyDefaults returns a walkOptions.keyDefaults hook looking up the list-map
+// key defaults of sequences in schemas.
+func keyDefaults(schemas map[*yaml.Node]*schema.Schema) func(seq *yaml.Node) map[string]any {
+	return func(seq *yaml.Node) map[string]any {
+		return schemas[seq].KeyDefaults()
+	}
+}
+
+// semanticLabel is the server-side apply semantics of a container, such as
+// "list-type: map keys=[name]", and the key of the container in its parent.
+type semanticLabel struct {
+	key   *yaml.Node
+	label string
+}
+
+// semanticLabels returns the labels of the containers under root whose
+// schema declares a list or map type. Each label is either appended to the
+// container's annotation by labelMark, or injected on its own by
+// injectLabels.
+func semanticLabels(root *yaml.Node, schemas map[*yaml.Node]*schema.Schema) map[*yaml.Node]semanticLabel {
+	labels := make(map[*yaml.Node]semanticLabel)
+	var walk func(key, node *yaml.Node)
+	walk = func(key, node *yaml.Node) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i < len(node.Content)-1; i += 2 {
+				walk(node.Content[i], node.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				walk(nil, item)
+			}
+		default:
+			return
+		}
+		if label := schemas[node].Semantics(); label != "" {
+			labels[node] = semanticLabel{key: key, label: label}
+		}
+	}
+	walk(nil, root)
+	return labels
+}
+
+// labelMark returns the label of node as a suffix for its annotation, such
+// as " [list-type: atomic]", and removes it from labels. It returns "" when
+// node has no label.
+func labelMark(labels map[*yaml.Node]semanticLabel, node *yaml.Node) string {
+	l, ok := labels[node]
+	if !ok {
+		return ""
+	}
+	delete(labels, node)
+	return " [" + l.label + "]"
+}
+
+// isLabelMark reports whether s is a semantic label as appended by
+// labelMark, such as " [map-type: atomic]".
+func isLabelMark(s string) bool {
+	label, ok := strings.CutPrefix(s, " [")
+	return ok && strings.HasSuffix(label, "]") &&
+		(strings.HasPrefix(label, "list-type: ") || strings.HasPrefix(label, "map-type: "))
+}
+
+// injectLabels comments the containers left in labels, which have no
+// annotation, with their label alone, such as "[map-type: atomic]".
+func injectLabels(labels map[*yaml.Node]semanticLabel, above bool) {
+	for node, l := range labels {
+		injectComment(AnnotationTarget{KeyNode: l.key, ValueNode: node}, "["+l.label+"]", above)
+	}
+}
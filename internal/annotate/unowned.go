@@ -0,0 +1,90 @@
+package annotate
+
+import (
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// UnownedComment marks fields that no manager owns (see Options.Unowned).
+const UnownedComment = "(unowned)"
+
+// IsNote reports whether an annotation (without its "#" or "//" marker) is
+// a note rather than an owner annotation: UnownedComment, possibly followed
+// by a semantic label, or a semantic label alone, such as
+// "[list-type: atomic]".
+func IsNote(comment string) bool {
+	if rest, ok := strings.CutPrefix(comment, UnownedComment); ok {
+		return rest == "" || isLabelMark(rest)
+	}
+	return isLabelMark(" " + comment)
+}
+
+// LeafCounts counts the leaves of an object (scalars and empty mappings or
+// sequences) by whether a manager owns them, directly or through an
+// atomically owned container.
+type LeafCounts struct {
+	Owned   int
+	Unowned int
+}
+
+// markUnowned counts the leaves under root and, when mark is set, comments
+// every unowned node with UnownedComment. A container whose contents are all
+// unowned is marked once, rather than each of its fields. The contents of an
+// atomically owned container count as owned, as they do for Options.Inherit.
+// metadata.managedFields is skipped, as it is stripped from the output.
+// Marked containers keep their semantic label, if any.
+func markUnowned(root *yaml.Node, targets map[*yaml.Node]AnnotationTarget, labels map[*yaml.Node]semanticLabel, above, mark bool) LeafCounts {
+	var counts LeafCounts
+	_, metadata := findMappingField(root, "metadata")
+
+	// walk reports whether anything in the subtree of node is owned. The
+	// unowned children of a node that is (partly) owned are marked.
+	var walk func(node *yaml.Node, covered bool) bool
+	walk = func(node *yaml.Node, covered bool) bool {
+		target, isTarget := targets[node]
+		covered = covered || (isTarget && target.Atomic)
+
+		if node.Kind != yaml.MappingNode && node.Kind != yaml.SequenceNode || len(node.Content) == 0 {
+			owned := covered || isTarget
+			if owned {
+				counts.Owned++
+			} else {
+				counts.Unowned++
+			}
+			return owned
+		}
+
+		type child struct{ key, value *yaml.Node }
+		var unowned []child
+		owned := covered || isTarget
+		visit := func(key, value *yaml.Node) {
+			if walk(value, covered) {
+				owned = true
+			} else {
+				unowned = append(unowned, child{key, value})
+			}
+		}
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i < len(node.Content)-1; i += 2 {
+				if node == metadata && node.Content[i].Value == "managedFields" {
+					continue
+				}
+				visit(node.Content[i], node.Content[i+1])
+			}
+		} else {
+			for _, item := range node.Content {
+				visit(nil, item)
+			}
+		}
+
+		if mark && (owned || node == root) {
+			for _, c := range unowned {
+				injectComment(AnnotationTarget{KeyNode: c.key, ValueNode: c.value}, UnownedComment+labelMark(labels, c.value), above)
+			}
+		}
+		return owned
+	}
+	walk(root, false)
+	return counts
+}
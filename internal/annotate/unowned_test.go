@@ -0,0 +1,122 @@
+package annotate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/stretchr/testify/assert"
+)
+
+const unownedYAML = `metadata:
+  name: web
+  uid: abc
+  managedFields:
+  - manager: kubectl
+spec:
+  replicas: 3
+  selector:
+    matchLabels:
+      app: web
+  strategy:
+    type: RollingUpdate
+    rollingUpdate:
+      maxSurge: 25%
+  ports:
+  - name: http
+    port: 80
+    protocol: TCP
+  - name: metrics
+    port: 9090
+`
+
+func unownedEntries(t *testing.T) []managed.ManagedFieldsEntry {
+	t.Helper()
+	return []managed.ManagedFieldsEntry{
+		{
+			Manager: "kubectl",
+			Time:    testNow.Add(-time.Hour),
+			FieldsV1: buildFieldsV1(t, `{"f:metadata":{"f:name":{}},"f:spec":{"f:replicas":{},"f:selector":{},
+				"f:ports":{"k:{\"name\":\"http\"}":{".":{},"f:name":{},"f:port":{}}}}}`),
+		},
+	}
+}
+
+func TestAnnotate_MarksUnowned(t *testing.T) {
+	root := parseYAML(t, unownedYAML)
+
+	var leaves LeafCounts
+	Annotate(root, unownedEntries(t), Options{Now: testNow, Mtime: MtimeHide, Unowned: true, Leaves: &leaves})
+	output := encodeYAML(t, root)
+
+	assert.Equal(t, `metadata:
+  name: web # kubectl
+  uid: abc # (unowned)
+  managedFields:
+  - manager: kubectl
+spec:
+  replicas: 3 # kubectl
+  selector: # kubectl
+    matchLabels:
+      app: web
+  strategy: # (unowned)
+    type: RollingUpdate
+    rollingUpdate:
+      maxSurge: 25%
+  ports:
+  - # kubectl
+    name: http # kubectl
+    port: 80 # kubectl
+    protocol: TCP # (unowned)
+  - # (unowned)
+    name: metrics
+    port: 9090
+`, output)
+	// Owned: name, replicas, app (atomic selector), http name and port.
+	// Unowned: uid, type, maxSurge, protocol, metrics name and port.
+	assert.Equal(t, LeafCounts{Owned: 5, Unowned: 6}, leaves)
+}
+
+func TestAnnotate_CountsLeavesWithoutMarking(t *testing.T) {
+	root := parseYAML(t, unownedYAML)
+
+	var leaves LeafCounts
+	Annotate(root, unownedEntries(t), Options{Now: testNow, Mtime: MtimeHide, Leaves: &leaves})
+	output := encodeYAML(t, root)
+
+	assert.NotContains(t, output, UnownedComment)
+	assert.Equal(t, LeafCounts{Owned: 5, Unowned: 6}, leaves)
+}
+
+func TestAnnotate_MarksUnownedAbove(t *testing.T) {
+	root := parseYAML(t, "spec:\n  replicas: 3\n  paused: false\n")
+	entries := []managed.ManagedFieldsEntry{
+		{Manager: "kubectl", Time: testNow, FieldsV1: buildFieldsV1(t, `{"f:spec":{"f:replicas":{}}}`)},
+	}
+
+	Annotate(root, entries, Options{Now: testNow, Mtime: MtimeHide, Unowned: true, Above: true})
+	output := encodeYAML(t, root)
+
+	assert.Equal(t, "spec:\n  # kubectl\n  replicas: 3\n  # (unowned)\n  paused: false\n", output)
+}
+
+func TestIsNote(t *testing.T) {
+	tests := []struct {
+		comment string
+		want    bool
+	}{
+		{UnownedComment, true},
+		{UnownedComment + " [list-type: map keys=[containerPort,protocol]]", true},
+		{"[map-type: atomic]", true},
+		{"[list-type: set]", true},
+		{"[helm]", false},
+		{"(unowned) [recent]", false},
+		{"kubectl (1h ago) [list-type: atomic]", false},
+		{"helm (ghost: f:replicas)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.comment, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsNote(tt.comment))
+		})
+	}
+}
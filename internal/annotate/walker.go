@@ -2,7 +2,7 @@ package annotate
 
 import (
 	"encoding/json"
-	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/ahmetb/kubectl-fields/internal/managed"
@@ -15,6 +15,7 @@ type AnnotationInfo struct {
 	Operation   string
 	Subresource string
 	Time        time.Time
+	APIVersion  string // API version of the managedFields entry
 }
 
 // AnnotationTarget pairs YAML key/value nodes with their ownership info.
@@ -25,6 +26,70 @@ type AnnotationTarget struct {
 	KeyNode   *yaml.Node // key in mapping (may be nil at root level)
 	ValueNode *yaml.Node // value in mapping (the owned node)
 	Info      AnnotationInfo
+	CoOwners  []AnnotationInfo // other managers owning the same field
+
+	// Atomic is set when an owner lists the node as a FieldsV1 leaf, which
+	// for a container means it owns all of its contents. A "." marker only
+	// claims the existence of the node.
+	Atomic bool
+}
+
+// addTarget records that info owns valueNode. The first owner becomes the
+// target's Info; further owners are appended to its CoOwners.
+func addTarget(targets map[*yaml.Node]AnnotationTarget, keyNode, valueNode *yaml.Node, info AnnotationInfo, atomic bool) {
+	target, ok := targets[valueNode]
+	if !ok {
+		targets[valueNode] = AnnotationTarget{KeyNode: keyNode, ValueNode: valueNode, Info: info, Atomic: atomic}
+		return
+	}
+	target.CoOwners = append(target.CoOwners, info)
+	target.Atomic = target.Atomic || atomic
+	targets[valueNode] = target
+}
+
+// unresolvedFunc is called by walkFieldsV1 for a FieldsV1 element key that
+// matches nothing in node, whose key in its parent mapping is parentKey (nil
+// for the root and for list items). fields is the FieldsV1 subtree under key.
+type unresolvedFunc func(node, parentKey *yaml.Node, key string, fields *yaml.Node)
+
+// walkOptions holds the optional inputs of walkFieldsV1. A nil *walkOptions
+// is valid and uses none of them.
+type walkOptions struct {
+	// unresolved is called for each f:, k:, v: or i: element that matches
+	// nothing in the YAML tree. Elements below an unresolved one are not
+	// visited.
+	unresolved unresolvedFunc
+
+	// keyDefaults returns the default values of the list-map keys of the
+	// items of seq (see schema.Schema.KeyDefaults), so a k: element matches
+	// an item that omits a defaulted key field.
+	keyDefaults func(seq *yaml.Node) map[string]any
+
+	// keyed is called for each list item matched by a k: element, with the
+	// key that addresses it.
+	keyed func(item *yaml.Node, assocKey map[string]any)
+}
+
+// missing calls w.unresolved, if set.
+func (w *walkOptions) missing(node, parentKey *yaml.Node, key string, fields *yaml.Node) {
+	if w != nil && w.unresolved != nil {
+		w.unresolved(node, parentKey, key, fields)
+	}
+}
+
+// defaults calls w.keyDefaults, if set.
+func (w *walkOptions) defaults(seq *yaml.Node) map[string]any {
+	if w == nil || w.keyDefaults == nil {
+		return nil
+	}
+	return w.keyDefaults(seq)
+}
+
+// matched calls w.keyed, if set.
+func (w *walkOptions) matched(item *yaml.Node, assocKey map[string]any) {
+	if w != nil && w.keyed != nil {
+		w.keyed(item, assocKey)
+	}
 }
 
 // walkFieldsV1 descends the FieldsV1 ownership tree in parallel with the
@@ -36,13 +101,17 @@ type AnnotationTarget struct {
 //     (nil when yamlNode is the document root)
 //   - fieldsNode: the current FieldsV1 MappingNode containing ownership keys
 //   - entry: the ManagedFieldsEntry providing manager/time metadata
-//   - targets: accumulator map keyed by ValueNode pointer (last-writer-wins)
-func walkFieldsV1(yamlNode *yaml.Node, parentKeyNode *yaml.Node, fieldsNode *yaml.Node, entry managed.ManagedFieldsEntry, targets map[*yaml.Node]AnnotationTarget) {
+//   - targets: accumulator map keyed by ValueNode pointer (see addTarget)
+//   - w: optional hooks (may be nil)
+func walkFieldsV1(yamlNode *yaml.Node, parentKeyNode *yaml.Node, fieldsNode *yaml.Node, entry managed.ManagedFieldsEntry, targets map[*yaml.Node]AnnotationTarget, w *walkOptions) {
 	if fieldsNode == nil || fieldsNode.Kind != yaml.MappingNode {
 		return
 	}
 
 	info := annotationFrom(entry)
+	missing := func(key string, fields *yaml.Node) {
+		w.missing(yamlNode, parentKeyNode, key, fields)
+	}
 
 	for i := 0; i < len(fieldsNode.Content)-1; i += 2 {
 		key := fieldsNode.Content[i].Value
@@ -54,54 +123,45 @@ func walkFieldsV1(yamlNode *yaml.Node, parentKeyNode *yaml.Node, fieldsNode *yam
 		case ".":
 			// Dot marker: the current yamlNode itself is owned.
 			// KeyNode comes from the parent level (may be nil at root).
-			targets[yamlNode] = AnnotationTarget{
-				KeyNode:   parentKeyNode,
-				ValueNode: yamlNode,
-				Info:      info,
-			}
+			addTarget(targets, parentKeyNode, yamlNode, info, false)
 
 		case "f":
 			// Field prefix: find the matching key-value pair in the YAML mapping.
 			targetKey, targetVal := findMappingField(yamlNode, content)
 			if targetKey == nil || targetVal == nil {
+				missing(key, val)
 				continue
 			}
 
 			if isLeaf(val) {
 				// Leaf field: store as annotation target.
-				targets[targetVal] = AnnotationTarget{
-					KeyNode:   targetKey,
-					ValueNode: targetVal,
-					Info:      info,
-				}
+				addTarget(targets, targetKey, targetVal, info, true)
 			} else {
 				// Non-leaf: recurse into the child mapping.
-				walkFieldsV1(targetVal, targetKey, val, entry, targets)
+				walkFieldsV1(targetVal, targetKey, val, entry, targets, w)
 			}
 
 		case "k":
 			// Associative key prefix: yamlNode is a SequenceNode containing
 			// MappingNodes. Parse the JSON key and find the matching item.
+			var item *yaml.Node
 			assocKey, err := managed.ParseAssociativeKey(content)
-			if err != nil || assocKey == nil {
-				continue
+			if err == nil && assocKey != nil {
+				item = findSequenceItemByKey(yamlNode, assocKey, w.defaults(yamlNode))
 			}
-			item := findSequenceItemByKey(yamlNode, assocKey)
 			if item == nil {
+				missing(key, val)
 				continue
 			}
+			w.matched(item, assocKey)
 			if isLeaf(val) {
 				// Rare: k: item is a leaf itself.
-				targets[item] = AnnotationTarget{
-					KeyNode:   nil,
-					ValueNode: item,
-					Info:      info,
-				}
+				addTarget(targets, nil, item, info, true)
 			} else {
 				// Non-leaf: recurse into the item's fields.
 				// Pass nil for parentKeyNode since sequence items
 				// don't have a key in the parent mapping sense.
-				walkFieldsV1(item, nil, val, entry, targets)
+				walkFieldsV1(item, nil, val, entry, targets, w)
 			}
 
 		case "v":
@@ -109,13 +169,31 @@ func walkFieldsV1(yamlNode *yaml.Node, parentKeyNode *yaml.Node, fieldsNode *yam
 			// ScalarNodes. Find the matching scalar by value.
 			item := findSequenceItemByValue(yamlNode, content)
 			if item == nil {
+				missing(key, val)
 				continue
 			}
 			// v: items are always leaves.
-			targets[item] = AnnotationTarget{
-				KeyNode:   nil,
-				ValueNode: item,
-				Info:      info,
+			addTarget(targets, nil, item, info, true)
+
+		case "i":
+			// Index prefix: yamlNode is a SequenceNode without merge
+			// keys. Find the item at the given position.
+			index, err := managed.ParseIndex(content)
+			if err != nil {
+				missing(key, val)
+				continue
+			}
+			item := findSequenceItemByIndex(yamlNode, index)
+			if item == nil {
+				missing(key, val)
+				continue
+			}
+			if isLeaf(val) {
+				addTarget(targets, nil, item, info, true)
+			} else {
+				// Non-leaf: recurse into the item, which (like k:
+				// items) has no key in the parent mapping.
+				walkFieldsV1(item, nil, val, entry, targets, w)
 			}
 
 		default:
@@ -147,8 +225,10 @@ func isLeaf(node *yaml.Node) bool {
 }
 
 // findSequenceItemByKey locates a MappingNode in a SequenceNode whose fields
-// match all key-value pairs in assocKey (from a FieldsV1 k: prefix).
-func findSequenceItemByKey(seq *yaml.Node, assocKey map[string]any) *yaml.Node {
+// match all key-value pairs in assocKey (from a FieldsV1 k: prefix). A key
+// field missing from an item matches when defaults holds the same value for
+// it, as the server fills in defaulted key fields before computing keys.
+func findSequenceItemByKey(seq *yaml.Node, assocKey map[string]any, defaults map[string]any) *yaml.Node {
 	if seq == nil || seq.Kind != yaml.SequenceNode {
 		return nil
 	}
@@ -156,7 +236,7 @@ func findSequenceItemByKey(seq *yaml.Node, assocKey map[string]any) *yaml.Node {
 		if item.Kind != yaml.MappingNode {
 			continue
 		}
-		if matchesAssociativeKey(item, assocKey) {
+		if matchesAssociativeKey(item, assocKey, defaults) {
 			return item
 		}
 	}
@@ -164,35 +244,96 @@ func findSequenceItemByKey(seq *yaml.Node, assocKey map[string]any) *yaml.Node {
 }
 
 // matchesAssociativeKey returns true if every key-value pair in assocKey has a
-// matching field in the YAML MappingNode.
-func matchesAssociativeKey(mapping *yaml.Node, assocKey map[string]any) bool {
+// matching field in the YAML MappingNode, or is missing from it and equal to
+// the field's entry in defaults (which may be nil).
+func matchesAssociativeKey(mapping *yaml.Node, assocKey map[string]any, defaults map[string]any) bool {
 	for field, jsonVal := range assocKey {
 		_, valNode := findMappingField(mapping, field)
 		if valNode == nil {
+			if def, ok := defaults[field]; ok && reflect.DeepEqual(def, jsonVal) {
+				continue
+			}
 			return false
 		}
-		if !matchValue(valNode.Value, jsonVal) {
+		if !matchValue(valNode, jsonVal) {
 			return false
 		}
 	}
 	return true
 }
 
-// matchValue compares a YAML scalar string value against a JSON-decoded value.
-// Handles string, float64 (JSON numbers), and bool comparisons.
-func matchValue(yamlVal string, jsonVal any) bool {
+// matchValue compares a YAML node against a JSON-decoded value, respecting
+// the resolved YAML tag of scalars: a JSON string only matches a string
+// scalar (so "80" does not match the number 80), a JSON number matches an
+// int or float scalar of equal value (so 0x50 matches 80), and null matches
+// a null scalar. JSON objects and arrays match mappings and sequences whose
+// fields and items match recursively.
+func matchValue(node *yaml.Node, jsonVal any) bool {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	switch v := jsonVal.(type) {
+	case map[string]any:
+		return node.Kind == yaml.MappingNode && len(node.Content) == 2*len(v) &&
+			matchesAssociativeKey(node, v, nil)
+	case []any:
+		if node.Kind != yaml.SequenceNode || len(node.Content) != len(v) {
+			return false
+		}
+		for i, item := range v {
+			if !matchValue(node.Content[i], item) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return false
+	}
 	switch v := jsonVal.(type) {
 	case string:
-		return yamlVal == v
+		return node.ShortTag() == "!!str" && node.Value == v
 	case float64:
-		return yamlVal == fmt.Sprintf("%g", v)
+		n, ok := scalarNumber(node)
+		return ok && n == v
 	case bool:
-		return yamlVal == fmt.Sprintf("%t", v)
+		var b bool
+		return node.ShortTag() == "!!bool" && node.Decode(&b) == nil && b == v
+	case nil:
+		return node.ShortTag() == "!!null"
 	default:
 		return false
 	}
 }
 
+// scalarNumber decodes an int or float scalar (in any YAML notation, such as
+// 0x50 or 1e3) into a float64, the type of JSON numbers.
+func scalarNumber(node *yaml.Node) (float64, bool) {
+	switch node.ShortTag() {
+	case "!!int", "!!float":
+	default:
+		return 0, false
+	}
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
 // findSequenceItemByValue locates a ScalarNode in a SequenceNode by its value.
 // The content parameter is JSON-encoded (e.g., `"example.com/foo"`); it is
 // decoded before comparison so that the quotes are stripped.
@@ -219,6 +360,15 @@ func findSequenceItemByValue(seq *yaml.Node, jsonContent string) *yaml.Node {
 	return nil
 }
 
+// findSequenceItemByIndex returns the item at index in a SequenceNode, or nil
+// if the node is not a sequence or the index is out of range.
+func findSequenceItemByIndex(seq *yaml.Node, index int) *yaml.Node {
+	if seq == nil || seq.Kind != yaml.SequenceNode || index >= len(seq.Content) {
+		return nil
+	}
+	return seq.Content[index]
+}
+
 // annotationFrom creates an AnnotationInfo from a ManagedFieldsEntry.
 func annotationFrom(entry managed.ManagedFieldsEntry) AnnotationInfo {
 	return AnnotationInfo{
@@ -226,5 +376,6 @@ func annotationFrom(entry managed.ManagedFieldsEntry) AnnotationInfo {
 		Operation:   entry.Operation,
 		Subresource: entry.Subresource,
 		Time:        entry.Time,
+		APIVersion:  entry.APIVersion,
 	}
 }
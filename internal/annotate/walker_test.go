@@ -108,7 +108,7 @@ func TestWalkFieldsV1_SimpleScalarFields(t *testing.T) {
 	}
 
 	targets := make(map[*yaml.Node]AnnotationTarget)
-	walkFieldsV1(yamlRoot, nil, fieldsV1, entry, targets)
+	walkFieldsV1(yamlRoot, nil, fieldsV1, entry, targets, nil)
 
 	assert.Len(t, targets, 2)
 
@@ -154,7 +154,7 @@ func TestWalkFieldsV1_NestedFields(t *testing.T) {
 	}
 
 	targets := make(map[*yaml.Node]AnnotationTarget)
-	walkFieldsV1(yamlRoot, nil, fieldsV1, entry, targets)
+	walkFieldsV1(yamlRoot, nil, fieldsV1, entry, targets, nil)
 
 	// Dot target on labels mapping: KeyNode = labelsKey, ValueNode = labelsMapping
 	dotTarget, ok := targets[labelsMapping]
@@ -192,7 +192,7 @@ func TestWalkFieldsV1_LeafContainerField(t *testing.T) {
 	}
 
 	targets := make(map[*yaml.Node]AnnotationTarget)
-	walkFieldsV1(yamlRoot, nil, fieldsV1, entry, targets)
+	walkFieldsV1(yamlRoot, nil, fieldsV1, entry, targets, nil)
 
 	// selector should be annotated as a leaf
 	target, ok := targets[selectorMapping]
@@ -224,7 +224,7 @@ func TestWalkFieldsV1_UnmanagedFieldsIgnored(t *testing.T) {
 	}
 
 	targets := make(map[*yaml.Node]AnnotationTarget)
-	walkFieldsV1(yamlRoot, nil, fieldsV1, entry, targets)
+	walkFieldsV1(yamlRoot, nil, fieldsV1, entry, targets, nil)
 
 	assert.Len(t, targets, 1, "only managed fields should have targets")
 
@@ -266,7 +266,7 @@ func TestFindSequenceItemByKey_SingleField(t *testing.T) {
 	redis := mappingNode(scalarNode("name"), scalarNode("redis"), scalarNode("image"), scalarNode("redis:6"))
 	seq := sequenceNode(nginx, redis)
 
-	found := findSequenceItemByKey(seq, map[string]any{"name": "nginx"})
+	found := findSequenceItemByKey(seq, map[string]any{"name": "nginx"}, nil)
 	assert.Equal(t, nginx, found, "should find the nginx item")
 }
 
@@ -275,7 +275,7 @@ func TestFindSequenceItemByKey_MultiField(t *testing.T) {
 	port443tcp := mappingNode(scalarNode("containerPort"), intScalarNode("443"), scalarNode("protocol"), scalarNode("TCP"))
 	seq := sequenceNode(port80tcp, port443tcp)
 
-	found := findSequenceItemByKey(seq, map[string]any{"containerPort": float64(80), "protocol": "TCP"})
+	found := findSequenceItemByKey(seq, map[string]any{"containerPort": float64(80), "protocol": "TCP"}, nil)
 	assert.Equal(t, port80tcp, found, "should find port 80 TCP item")
 }
 
@@ -283,28 +283,70 @@ func TestFindSequenceItemByKey_NotFound(t *testing.T) {
 	item := mappingNode(scalarNode("name"), scalarNode("nginx"))
 	seq := sequenceNode(item)
 
-	found := findSequenceItemByKey(seq, map[string]any{"name": "redis"})
+	found := findSequenceItemByKey(seq, map[string]any{"name": "redis"}, nil)
 	assert.Nil(t, found, "should return nil when no match")
 }
 
 // --- matchValue tests ---
 
+// yamlValue parses src and returns its root node, with the scalar tags
+// resolved as in real input.
+func yamlValue(t *testing.T, src string) *yaml.Node {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc.Content[0]
+}
+
 func TestMatchValue_Types(t *testing.T) {
 	t.Run("string match", func(t *testing.T) {
-		assert.True(t, matchValue("nginx", "nginx"))
-		assert.False(t, matchValue("nginx", "redis"))
+		assert.True(t, matchValue(yamlValue(t, "nginx"), "nginx"))
+		assert.False(t, matchValue(yamlValue(t, "nginx"), "redis"))
 	})
 	t.Run("float64 match (JSON number)", func(t *testing.T) {
-		assert.True(t, matchValue("80", float64(80)))
-		assert.False(t, matchValue("443", float64(80)))
+		assert.True(t, matchValue(yamlValue(t, "80"), float64(80)))
+		assert.False(t, matchValue(yamlValue(t, "443"), float64(80)))
 	})
 	t.Run("bool match", func(t *testing.T) {
-		assert.True(t, matchValue("true", true))
-		assert.True(t, matchValue("false", false))
-		assert.False(t, matchValue("true", false))
+		assert.True(t, matchValue(yamlValue(t, "true"), true))
+		assert.True(t, matchValue(yamlValue(t, "false"), false))
+		assert.False(t, matchValue(yamlValue(t, "true"), false))
 	})
 	t.Run("unsupported type returns false", func(t *testing.T) {
-		assert.False(t, matchValue("anything", []string{"not", "a", "match"}))
+		assert.False(t, matchValue(yamlValue(t, "anything"), []string{"not", "a", "match"}))
+	})
+	t.Run("large integers", func(t *testing.T) {
+		assert.True(t, matchValue(yamlValue(t, "1000000"), float64(1000000)))
+		assert.True(t, matchValue(yamlValue(t, "1e6"), float64(1000000)))
+		assert.False(t, matchValue(yamlValue(t, "1000001"), float64(1000000)))
+	})
+	t.Run("YAML integer notations", func(t *testing.T) {
+		assert.True(t, matchValue(yamlValue(t, "0x50"), float64(80)))
+		assert.True(t, matchValue(yamlValue(t, "0o120"), float64(80)))
+	})
+	t.Run("quoted numbers are strings", func(t *testing.T) {
+		assert.False(t, matchValue(yamlValue(t, `"80"`), float64(80)))
+		assert.True(t, matchValue(yamlValue(t, `"80"`), "80"))
+		assert.False(t, matchValue(yamlValue(t, "80"), "80"))
+	})
+	t.Run("quoted booleans are strings", func(t *testing.T) {
+		assert.False(t, matchValue(yamlValue(t, `"true"`), true))
+		assert.True(t, matchValue(yamlValue(t, `"true"`), "true"))
+	})
+	t.Run("null", func(t *testing.T) {
+		assert.True(t, matchValue(yamlValue(t, "null"), nil))
+		assert.True(t, matchValue(yamlValue(t, "~"), nil))
+		assert.False(t, matchValue(yamlValue(t, `"null"`), nil))
+	})
+	t.Run("objects and arrays", func(t *testing.T) {
+		obj := map[string]any{"group": "apps", "port": float64(80)}
+		assert.True(t, matchValue(yamlValue(t, "{group: apps, port: 80}"), obj))
+		assert.False(t, matchValue(yamlValue(t, "{group: apps, port: 80, extra: x}"), obj))
+		assert.False(t, matchValue(yamlValue(t, "{group: apps}"), obj))
+		assert.True(t, matchValue(yamlValue(t, "[a, 1]"), []any{"a", float64(1)}))
+		assert.False(t, matchValue(yamlValue(t, "[a]"), []any{"a", float64(1)}))
 	})
 }
 
@@ -351,7 +393,7 @@ func TestWalkFieldsV1_AssociativeKey(t *testing.T) {
 	}
 
 	targets := make(map[*yaml.Node]AnnotationTarget)
-	walkFieldsV1(seq, nil, fieldsV1, entry, targets)
+	walkFieldsV1(seq, nil, fieldsV1, entry, targets, nil)
 
 	// image value should be targeted
 	target, ok := targets[imageVal]
@@ -379,7 +421,7 @@ func TestWalkFieldsV1_AssociativeKeyDot(t *testing.T) {
 	}
 
 	targets := make(map[*yaml.Node]AnnotationTarget)
-	walkFieldsV1(seq, nil, fieldsV1, entry, targets)
+	walkFieldsV1(seq, nil, fieldsV1, entry, targets, nil)
 
 	// The item MappingNode itself should be targeted with dot marker.
 	// For k: items with dot, KeyNode is nil and ValueNode is the item.
@@ -406,7 +448,7 @@ func TestWalkFieldsV1_SetValue(t *testing.T) {
 	}
 
 	targets := make(map[*yaml.Node]AnnotationTarget)
-	walkFieldsV1(seq, nil, fieldsV1, entry, targets)
+	walkFieldsV1(seq, nil, fieldsV1, entry, targets, nil)
 
 	// The scalar should be targeted.
 	target, ok := targets[fooScalar]
@@ -415,3 +457,65 @@ func TestWalkFieldsV1_SetValue(t *testing.T) {
 	assert.Equal(t, fooScalar, target.ValueNode)
 	assert.Equal(t, "finalizerpatcher", target.Info.Manager)
 }
+
+func TestWalkFieldsV1_Index(t *testing.T) {
+	// YAML: sequence of two mappings without merge keys.
+	first := mappingNode(scalarNode("x"), scalarNode("1"))
+	secondX := scalarNode("2")
+	second := mappingNode(scalarNode("x"), secondX)
+	seq := sequenceNode(first, second)
+
+	// FieldsV1: {i:0: {}, i:1: {f:x: {}}}
+	fieldsV1 := mappingNode(
+		scalarNode("i:0"), emptyMapping(),
+		scalarNode("i:1"), mappingNode(
+			scalarNode("f:x"), emptyMapping(),
+		),
+	)
+
+	entry := managed.ManagedFieldsEntry{
+		Manager: "test-manager",
+		Time:    time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC),
+	}
+
+	targets := make(map[*yaml.Node]AnnotationTarget)
+	walkFieldsV1(seq, nil, fieldsV1, entry, targets, nil)
+
+	target, ok := targets[first]
+	assert.True(t, ok, "leaf i: item should be in targets")
+	assert.Nil(t, target.KeyNode, "i: target should have nil KeyNode")
+	assert.Equal(t, "test-manager", target.Info.Manager)
+
+	target, ok = targets[secondX]
+	assert.True(t, ok, "field inside i: item should be in targets")
+	assert.Equal(t, "test-manager", target.Info.Manager)
+	assert.Len(t, targets, 2)
+}
+
+func TestWalkFieldsV1_IndexOutOfRange(t *testing.T) {
+	seq := sequenceNode(scalarNode("a"))
+	fieldsV1 := mappingNode(
+		scalarNode("i:1"), emptyMapping(),
+		scalarNode("i:x"), emptyMapping(),
+	)
+
+	targets := make(map[*yaml.Node]AnnotationTarget)
+	walkFieldsV1(seq, nil, fieldsV1, managed.ManagedFieldsEntry{Manager: "m"}, targets, nil)
+	assert.Empty(t, targets)
+}
+
+func TestFindSequenceItemByKey_DefaultedKeyField(t *testing.T) {
+	// The object omits protocol, which the server defaults to TCP before
+	// recording the key {"containerPort":80,"protocol":"TCP"}.
+	port80 := mappingNode(scalarNode("containerPort"), intScalarNode("80"))
+	port53udp := mappingNode(scalarNode("containerPort"), intScalarNode("53"), scalarNode("protocol"), scalarNode("UDP"))
+	seq := sequenceNode(port80, port53udp)
+	key := map[string]any{"containerPort": float64(80), "protocol": "TCP"}
+
+	assert.Nil(t, findSequenceItemByKey(seq, key, nil), "no match without defaults")
+	assert.Equal(t, port80, findSequenceItemByKey(seq, key, map[string]any{"protocol": "TCP"}))
+	assert.Nil(t, findSequenceItemByKey(seq, key, map[string]any{"protocol": "SCTP"}),
+		"a different default does not match")
+	assert.Nil(t, findSequenceItemByKey(seq, map[string]any{"containerPort": float64(53), "protocol": "TCP"},
+		map[string]any{"protocol": "TCP"}), "a present key field must match")
+}
@@ -0,0 +1,148 @@
+// Package audit adapts Kubernetes audit log events (audit.k8s.io Event
+// objects, as written by the API server's log backend) into documents that
+// can be annotated like any other resource.
+package audit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"go.yaml.in/yaml/v3"
+)
+
+// Event holds the parts of an audit event needed to annotate its object.
+type Event struct {
+	User      string
+	Verb      string
+	UserAgent string
+	Resource  string // "namespace/resource/name" from objectRef, as available
+
+	RequestReceived time.Time
+	Stage           time.Time
+
+	// Object is the responseObject as a DocumentNode, or nil if the event
+	// carries none (not logged at RequestResponse level, not yet complete,
+	// or a Status response such as for deletions).
+	Object *yaml.Node
+}
+
+// FromDocument converts an audit Event document. ok is false when doc is not
+// an audit Event (apiVersion audit.k8s.io/*, kind Event).
+func FromDocument(doc *yaml.Node) (ev Event, ok bool, err error) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return Event{}, false, nil
+	}
+	root := doc.Content[0]
+	if parser.MapScalar(root, "kind") != "Event" || !strings.HasPrefix(parser.MapScalar(root, "apiVersion"), "audit.k8s.io/") {
+		return Event{}, false, nil
+	}
+
+	ev = Event{
+		User:      parser.MapScalar(parser.MapValue(root, "user"), "username"),
+		Verb:      parser.MapScalar(root, "verb"),
+		UserAgent: parser.MapScalar(root, "userAgent"),
+		Resource:  objectRef(parser.MapValue(root, "objectRef")),
+	}
+	if ev.RequestReceived, err = parseTime(parser.MapScalar(root, "requestReceivedTimestamp")); err != nil {
+		return Event{}, true, fmt.Errorf("audit event %s: requestReceivedTimestamp: %w", parser.MapScalar(root, "auditID"), err)
+	}
+	if ev.Stage, err = parseTime(parser.MapScalar(root, "stageTimestamp")); err != nil {
+		return Event{}, true, fmt.Errorf("audit event %s: stageTimestamp: %w", parser.MapScalar(root, "auditID"), err)
+	}
+
+	object := parser.MapValue(root, "responseObject")
+	if object != nil && object.Kind == yaml.MappingNode && parser.MapScalar(object, "kind") != "Status" {
+		ev.Object = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{object}}
+	}
+	return ev, true, nil
+}
+
+// Mutating reports whether the event's verb modifies objects.
+func (e Event) Mutating() bool {
+	switch e.Verb {
+	case "create", "update", "patch", "delete", "deletecollection":
+		return true
+	default:
+		return false
+	}
+}
+
+// Time returns the time the event completed, falling back to the time the
+// request was received for events logged before completion.
+func (e Event) Time() time.Time {
+	if !e.Stage.IsZero() {
+		return e.Stage
+	}
+	return e.RequestReceived
+}
+
+// Header returns the comment lines that head the event's object in the
+// output. matched is the managedFields entry written by the event, if known.
+func (e Event) Header(matched *managed.ManagedFieldsEntry) string {
+	lines := []string{
+		"audit: " + strings.TrimSpace(e.Verb+" "+e.Resource),
+		"  user: " + e.User,
+		"  userAgent: " + e.UserAgent,
+		"  time: " + e.Time().UTC().Format(time.RFC3339),
+	}
+	if matched != nil {
+		entry := matched.Manager
+		if matched.Subresource != "" {
+			entry += " /" + matched.Subresource
+		}
+		lines = append(lines, "  managedFields entry: "+entry+" ("+matched.Operation+")")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MatchEntry returns the managedFields entry written by the event: the entry
+// whose time lies between the second the request was received and the time
+// the event completed, preferring the one closest to completion. managedFields
+// times have one-second precision, so the window starts at the whole second.
+func MatchEntry(entries []managed.ManagedFieldsEntry, e Event) (*managed.ManagedFieldsEntry, bool) {
+	end := e.Time()
+	if end.IsZero() {
+		return nil, false
+	}
+	start := end
+	if !e.RequestReceived.IsZero() {
+		start = e.RequestReceived
+	}
+	start = start.Truncate(time.Second)
+
+	var best *managed.ManagedFieldsEntry
+	for i := range entries {
+		t := entries[i].Time
+		if t.Before(start) || t.After(end) {
+			continue
+		}
+		if best == nil || t.After(best.Time) {
+			best = &entries[i]
+		}
+	}
+	return best, best != nil
+}
+
+// objectRef renders an audit objectRef as "namespace/resource/name",
+// omitting empty parts and appending "/subresource" when present.
+func objectRef(ref *yaml.Node) string {
+	var parts []string
+	for _, key := range []string{"namespace", "resource", "name", "subresource"} {
+		if v := parser.MapScalar(ref, key); v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// parseTime parses an audit timestamp (RFC 3339 with optional fractional
+// seconds). An empty string yields the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmetb/kubectl-fields/internal/managed"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v3"
+)
+
+// decodeDocuments returns the documents read from r.
+func decodeDocuments(t *testing.T, r io.Reader) []*yaml.Node {
+	t.Helper()
+	dec, err := parser.NewDecoder(r)
+	require.NoError(t, err)
+
+	var docs []*yaml.Node
+	for {
+		tok, err := dec.Next()
+		if err == io.EOF {
+			return docs
+		}
+		require.NoError(t, err)
+		docs = append(docs, tok.Node)
+	}
+}
+
+func loadEvents(t *testing.T) []Event {
+	t.Helper()
+	f, err := os.Open("../../testdata/audit/audit.log")
+	require.NoError(t, err)
+	defer f.Close()
+
+	var events []Event
+	for _, doc := range decodeDocuments(t, f) {
+		ev, ok, err := FromDocument(doc)
+		require.NoError(t, err)
+		require.True(t, ok)
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestFromDocument(t *testing.T) {
+	events := loadEvents(t)
+	require.Len(t, events, 4)
+
+	ev := events[2]
+	assert.Equal(t, "alice@example.com", ev.User)
+	assert.Equal(t, "patch", ev.Verb)
+	assert.Equal(t, "kubectl/v1.30.0 (linux/amd64) kubernetes/7c48c2b", ev.UserAgent)
+	assert.Equal(t, "default/deployments/nginx-deployment", ev.Resource)
+	assert.Equal(t, time.Date(2024, 4, 10, 0, 44, 50, 301000000, time.UTC), ev.RequestReceived)
+	assert.Equal(t, time.Date(2024, 4, 10, 0, 44, 50, 412000000, time.UTC), ev.Stage)
+	assert.True(t, ev.Mutating())
+	require.NotNil(t, ev.Object)
+
+	// Read-only verbs are not mutating.
+	assert.False(t, events[0].Mutating())
+	// RequestReceived stage: no response object yet.
+	assert.Nil(t, events[1].Object)
+	// Status responses (deletions) carry no object to annotate.
+	assert.Nil(t, events[3].Object)
+}
+
+func TestFromDocument_NotAnAuditEvent(t *testing.T) {
+	for _, input := range []string{
+		"kind: Event\napiVersion: v1\n",
+		"kind: Deployment\napiVersion: apps/v1\n",
+	} {
+		docs := decodeDocuments(t, strings.NewReader(input))
+		_, ok, err := FromDocument(docs[0])
+		require.NoError(t, err)
+		assert.False(t, ok, input)
+	}
+}
+
+func TestFromDocument_BadTimestamp(t *testing.T) {
+	docs := decodeDocuments(t, strings.NewReader("kind: Event\napiVersion: audit.k8s.io/v1\nauditID: x\nstageTimestamp: yesterday\n"))
+	_, ok, err := FromDocument(docs[0])
+	assert.True(t, ok)
+	assert.ErrorContains(t, err, "audit event x: stageTimestamp")
+}
+
+func TestMatchEntry(t *testing.T) {
+	ev := loadEvents(t)[2]
+	entries, err := managed.ExtractManagedFields(ev.Object.Content[0])
+	require.NoError(t, err)
+
+	matched, ok := MatchEntry(entries, ev)
+	require.True(t, ok)
+	assert.Equal(t, "kubectl-client-side-apply", matched.Manager)
+
+	// An event outside of every entry's time matches nothing.
+	ev.RequestReceived = ev.RequestReceived.Add(time.Hour)
+	ev.Stage = ev.Stage.Add(time.Hour)
+	_, ok = MatchEntry(entries, ev)
+	assert.False(t, ok)
+}
+
+func TestMatchEntry_PrefersLatest(t *testing.T) {
+	base := time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC)
+	entries := []managed.ManagedFieldsEntry{
+		{Manager: "a", Time: base},
+		{Manager: "b", Time: base.Add(time.Second)},
+		{Manager: "c", Time: base.Add(3 * time.Second)},
+	}
+	ev := Event{RequestReceived: base.Add(200 * time.Millisecond), Stage: base.Add(1500 * time.Millisecond)}
+	matched, ok := MatchEntry(entries, ev)
+	require.True(t, ok)
+	assert.Equal(t, "b", matched.Manager)
+}
+
+func TestHeader(t *testing.T) {
+	ev := loadEvents(t)[2]
+	want := `audit: patch default/deployments/nginx-deployment
+  user: alice@example.com
+  userAgent: kubectl/v1.30.0 (linux/amd64) kubernetes/7c48c2b
+  time: 2024-04-10T00:44:50Z`
+	assert.Equal(t, want, ev.Header(nil))
+
+	matched := &managed.ManagedFieldsEntry{Manager: "kube-controller-manager", Operation: "Update", Subresource: "status"}
+	assert.Equal(t, want+"\n  managedFields entry: kube-controller-manager /status (Update)", ev.Header(matched))
+}
@@ -0,0 +1,79 @@
+package input
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// archiveExtensions lists the file name suffixes read as tar archives, with
+// whether the archive is gzip-compressed.
+var archiveExtensions = map[string]bool{
+	".tar":    false,
+	".tar.gz": true,
+	".tgz":    true,
+}
+
+// IsArchive reports whether path names a tar archive (.tar, .tar.gz or .tgz)
+// whose files should be read instead of the archive itself.
+func IsArchive(path string) bool {
+	_, ok := archiveCompression(path)
+	return ok
+}
+
+// archiveCompression returns whether the archive at path is gzip-compressed.
+func archiveCompression(path string) (gzipped, ok bool) {
+	lower := strings.ToLower(path)
+	for ext, gz := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return gz, true
+		}
+	}
+	return false, false
+}
+
+// WalkArchive calls fn for each regular file with one of the Extensions in
+// the tar archive at path, in archive order. name is the path of the file
+// within the archive. Walking stops at the first error returned by fn.
+func WalkArchive(path string, fn func(name string, r io.Reader) error) error {
+	gzipped, ok := archiveCompression(path)
+	if !ok {
+		return fmt.Errorf("%s: not a tar archive", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: reading archive: %w", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || !hasExtension(hdr.Name) {
+			continue
+		}
+		if err := fn(strings.TrimPrefix(hdr.Name, "./"), tr); err != nil {
+			return err
+		}
+	}
+}
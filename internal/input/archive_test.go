@@ -0,0 +1,88 @@
+package input
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeArchive writes a tar archive (gzip-compressed for .tar.gz and .tgz
+// names) holding the given files, in order, and returns its path.
+func makeArchive(t *testing.T, name string, files [][2]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var w io.Writer = f
+	if gzipped, _ := archiveCompression(name); gzipped {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	for _, file := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     file[0],
+			Mode:     0o644,
+			Size:     int64(len(file[1])),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(file[1]))
+		require.NoError(t, err)
+	}
+	return path
+}
+
+func TestIsArchive(t *testing.T) {
+	for path, want := range map[string]bool{
+		"bundle.tar":    true,
+		"bundle.tar.gz": true,
+		"bundle.TGZ":    true,
+		"bundle.gz":     false,
+		"deploy.yaml":   false,
+	} {
+		assert.Equal(t, want, IsArchive(path), path)
+	}
+}
+
+func TestWalkArchive(t *testing.T) {
+	for _, name := range []string{"bundle.tar", "bundle.tar.gz", "bundle.tgz"} {
+		t.Run(name, func(t *testing.T) {
+			path := makeArchive(t, name, [][2]string{
+				{"./default/deployments.yaml", "kind: Deployment\n"},
+				{"README.txt", "not a manifest\n"},
+				{"kube-system/pods.json", `{"kind":"Pod"}`},
+			})
+
+			var names, contents []string
+			err := WalkArchive(path, func(name string, r io.Reader) error {
+				b, err := io.ReadAll(r)
+				require.NoError(t, err)
+				names = append(names, name)
+				contents = append(contents, string(b))
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"default/deployments.yaml", "kube-system/pods.json"}, names)
+			assert.Equal(t, []string{"kind: Deployment\n", `{"kind":"Pod"}`}, contents)
+		})
+	}
+}
+
+func TestWalkArchive_NotGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tgz")
+	require.NoError(t, os.WriteFile(path, []byte("plain text"), 0o644))
+
+	err := WalkArchive(path, func(string, io.Reader) error { return nil })
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), path)
+}
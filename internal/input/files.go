@@ -0,0 +1,113 @@
+// Package input resolves the positional arguments of the command (files,
+// directories and glob patterns) into the list of files to read.
+package input
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Stdin is the argument that stands for standard input.
+const Stdin = "-"
+
+// Extensions lists the file extensions read from directories. Files named
+// explicitly (or matched by a glob) are read regardless of their extension.
+var Extensions = []string{".yaml", ".yml", ".json"}
+
+// Files expands args into the files to read, in a deterministic order:
+// arguments are kept in the order given, glob matches and directory entries
+// are sorted lexically. Arguments that name an existing path are taken
+// literally even if they contain glob metacharacters. Directories contribute
+// the files with one of the Extensions; with recursive, their subdirectories
+// are walked too. Each file is returned once, at its first occurrence.
+func Files(args []string, recursive bool) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, arg := range args {
+		if arg == Stdin {
+			add(arg)
+			continue
+		}
+
+		paths := []string{arg}
+		if _, err := os.Stat(arg); err != nil && hasGlobMeta(arg) {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s: no files match the pattern", arg)
+			}
+			sort.Strings(matches)
+			paths = matches
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(path)
+				continue
+			}
+			dirFiles, err := dirFiles(path, recursive)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range dirFiles {
+				add(f)
+			}
+		}
+	}
+	return files, nil
+}
+
+// dirFiles returns the files with a known extension in dir, in lexical
+// order, descending into subdirectories when recursive.
+func dirFiles(dir string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hasExtension(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// hasExtension reports whether path ends in one of the Extensions.
+func hasExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGlobMeta reports whether path contains glob metacharacters.
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[`)
+}
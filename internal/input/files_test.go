@@ -0,0 +1,89 @@
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeTree creates files (with empty content) under a temporary directory
+// and returns the directory.
+func makeTree(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+	return dir
+}
+
+func rel(t *testing.T, dir string, files []string) []string {
+	t.Helper()
+	out := make([]string, len(files))
+	for i, f := range files {
+		r, err := filepath.Rel(dir, f)
+		require.NoError(t, err)
+		out[i] = filepath.ToSlash(r)
+	}
+	return out
+}
+
+func TestFiles_Directory(t *testing.T) {
+	dir := makeTree(t, "b.yaml", "a.json", "c.yml", "notes.txt", "sub/d.yaml")
+
+	files, err := Files([]string{dir}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.json", "b.yaml", "c.yml"}, rel(t, dir, files))
+}
+
+func TestFiles_Recursive(t *testing.T) {
+	dir := makeTree(t, "b.yaml", "sub/z/e.json", "sub/d.yaml", "sub/readme.md")
+
+	files, err := Files([]string{dir}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.yaml", "sub/d.yaml", "sub/z/e.json"}, rel(t, dir, files))
+}
+
+func TestFiles_GlobAndOrder(t *testing.T) {
+	dir := makeTree(t, "b.yaml", "a.yaml", "c.txt", "z.json")
+
+	// Arguments keep their order; glob matches are sorted; duplicates are
+	// dropped; explicitly matched files are read regardless of extension.
+	files, err := Files([]string{
+		filepath.Join(dir, "z.json"),
+		filepath.Join(dir, "*.yaml"),
+		filepath.Join(dir, "c.*"),
+		filepath.Join(dir, "a.yaml"),
+	}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"z.json", "a.yaml", "b.yaml", "c.txt"}, rel(t, dir, files))
+}
+
+func TestFiles_LiteralNameWithGlobMeta(t *testing.T) {
+	dir := makeTree(t, "[x].yaml")
+
+	files, err := Files([]string{filepath.Join(dir, "[x].yaml")}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[x].yaml"}, rel(t, dir, files))
+}
+
+func TestFiles_Stdin(t *testing.T) {
+	files, err := Files([]string{Stdin}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{Stdin}, files)
+}
+
+func TestFiles_Errors(t *testing.T) {
+	dir := makeTree(t, "a.yaml")
+
+	_, err := Files([]string{filepath.Join(dir, "*.json")}, false)
+	assert.ErrorContains(t, err, "no files match the pattern")
+
+	_, err = Files([]string{filepath.Join(dir, "missing.yaml")}, false)
+	assert.ErrorContains(t, err, "missing.yaml")
+}
@@ -1,9 +1,11 @@
 package managed
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/ahmetb/kubectl-fields/internal/parser"
 	"go.yaml.in/yaml/v3"
 )
 
@@ -21,21 +23,23 @@ type ManagedFieldsEntry struct {
 // ExtractManagedFields finds and parses managedFields entries from a
 // Kubernetes resource root MappingNode. Returns nil, nil if metadata or
 // managedFields are not present (not an error).
+//
+// Entries that fail to parse (for example, with a malformed time) are
+// skipped: the remaining entries are returned together with an error naming
+// each skipped entry, so callers can choose to fail or to continue with the
+// valid entries.
 func ExtractManagedFields(root *yaml.Node) ([]ManagedFieldsEntry, error) {
 	if root.Kind != yaml.MappingNode {
 		return nil, fmt.Errorf("expected MappingNode, got kind %d", root.Kind)
 	}
 
-	metadataNode, ok := getMapValueNode(root, "metadata")
-	if !ok {
-		return nil, nil
-	}
-	if metadataNode.Kind != yaml.MappingNode {
+	metadataNode := parser.MapValue(root, "metadata")
+	if metadataNode == nil || metadataNode.Kind != yaml.MappingNode {
 		return nil, nil
 	}
 
-	managedNode, ok := getMapValueNode(metadataNode, "managedFields")
-	if !ok {
+	managedNode := parser.MapValue(metadataNode, "managedFields")
+	if managedNode == nil {
 		return nil, nil
 	}
 	if managedNode.Kind != yaml.SequenceNode {
@@ -43,15 +47,17 @@ func ExtractManagedFields(root *yaml.Node) ([]ManagedFieldsEntry, error) {
 	}
 
 	var entries []ManagedFieldsEntry
-	for _, item := range managedNode.Content {
+	var errs []error
+	for i, item := range managedNode.Content {
 		entry, err := parseManagedFieldEntry(item)
 		if err != nil {
-			return nil, fmt.Errorf("parsing managedFields entry: %w", err)
+			errs = append(errs, fmt.Errorf("parsing managedFields entry %d: %w", i, err))
+			continue
 		}
 		entries = append(entries, entry)
 	}
 
-	return entries, nil
+	return entries, errors.Join(errs...)
 }
 
 // parseManagedFieldEntry parses a single managedFields entry MappingNode.
@@ -60,56 +66,20 @@ func parseManagedFieldEntry(node *yaml.Node) (ManagedFieldsEntry, error) {
 		return ManagedFieldsEntry{}, fmt.Errorf("expected MappingNode for entry, got kind %d", node.Kind)
 	}
 
-	var entry ManagedFieldsEntry
-
-	if v, ok := getMapValue(node, "manager"); ok {
-		entry.Manager = v
-	}
-	if v, ok := getMapValue(node, "operation"); ok {
-		entry.Operation = v
-	}
-	if v, ok := getMapValue(node, "subresource"); ok {
-		entry.Subresource = v
-	}
-	if v, ok := getMapValue(node, "apiVersion"); ok {
-		entry.APIVersion = v
-	}
-	if v, ok := getMapValue(node, "time"); ok {
-		t, err := time.Parse(time.RFC3339, v)
+	entry := ManagedFieldsEntry{
+		Manager:     parser.MapScalar(node, "manager"),
+		Operation:   parser.MapScalar(node, "operation"),
+		Subresource: parser.MapScalar(node, "subresource"),
+		APIVersion:  parser.MapScalar(node, "apiVersion"),
+		FieldsV1:    parser.MapValue(node, "fieldsV1"),
+	}
+	if v := parser.MapValue(node, "time"); v != nil {
+		t, err := time.Parse(time.RFC3339, v.Value)
 		if err != nil {
-			return ManagedFieldsEntry{}, fmt.Errorf("parsing time %q: %w", v, err)
+			return ManagedFieldsEntry{}, fmt.Errorf("parsing time %q: %w", v.Value, err)
 		}
 		entry.Time = t
 	}
-	if n, ok := getMapValueNode(node, "fieldsV1"); ok {
-		entry.FieldsV1 = n
-	}
 
 	return entry, nil
 }
-
-// getMapValue finds a key in a MappingNode and returns its string value.
-func getMapValue(mapping *yaml.Node, key string) (string, bool) {
-	if mapping.Kind != yaml.MappingNode {
-		return "", false
-	}
-	for i := 0; i < len(mapping.Content)-1; i += 2 {
-		if mapping.Content[i].Value == key {
-			return mapping.Content[i+1].Value, true
-		}
-	}
-	return "", false
-}
-
-// getMapValueNode finds a key in a MappingNode and returns the value Node.
-func getMapValueNode(mapping *yaml.Node, key string) (*yaml.Node, bool) {
-	if mapping.Kind != yaml.MappingNode {
-		return nil, false
-	}
-	for i := 0; i < len(mapping.Content)-1; i += 2 {
-		if mapping.Content[i].Value == key {
-			return mapping.Content[i+1], true
-		}
-	}
-	return nil, false
-}
@@ -74,3 +74,28 @@ func TestExtractManagedFields_NoManagedFields(t *testing.T) {
 	require.NoError(t, err)
 	assert.Nil(t, entries)
 }
+
+func TestExtractManagedFields_SkipsMalformedEntries(t *testing.T) {
+	data := []byte(`metadata:
+  managedFields:
+  - manager: good
+    operation: Apply
+    time: "2024-04-10T00:34:50Z"
+  - manager: bad
+    operation: Update
+    time: yesterday
+  - manager: also-good
+    operation: Update
+`)
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal(data, &doc))
+
+	entries, err := ExtractManagedFields(doc.Content[0])
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parsing managedFields entry 1")
+	assert.Contains(t, err.Error(), `"yesterday"`)
+
+	require.Len(t, entries, 2)
+	assert.Equal(t, "good", entries[0].Manager)
+	assert.Equal(t, "also-good", entries[1].Manager)
+}
@@ -3,6 +3,7 @@ package managed
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -30,3 +31,13 @@ func ParseAssociativeKey(jsonStr string) (map[string]any, error) {
 	}
 	return result, nil
 }
+
+// ParseIndex parses the content of an i: prefix key, the zero-based position
+// of an item in a list without merge keys. For example, "2" returns 2.
+func ParseIndex(content string) (int, error) {
+	index, err := strconv.Atoi(content)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("parsing list index %q: not a non-negative integer", content)
+	}
+	return index, nil
+}
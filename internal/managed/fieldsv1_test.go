@@ -54,3 +54,16 @@ func TestParseAssociativeKey_Invalid(t *testing.T) {
 	_, err := ParseAssociativeKey("not-json")
 	assert.Error(t, err)
 }
+
+func TestParseIndex(t *testing.T) {
+	index, err := ParseIndex("2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, index)
+}
+
+func TestParseIndex_Invalid(t *testing.T) {
+	for _, content := range []string{"", "-1", "one", "1.5"} {
+		_, err := ParseIndex(content)
+		assert.Error(t, err, content)
+	}
+}
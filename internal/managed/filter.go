@@ -0,0 +1,153 @@
+package managed
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ManagerFilter selects managedFields entries by manager name. A pattern is
+// a glob (as in path.Match), or a regular expression when written between
+// slashes, such as "/^kube-/". An entry is kept when its manager matches one
+// of Include (or Include is empty) and none of Exclude.
+type ManagerFilter struct {
+	include, exclude []managerPattern
+}
+
+// managerPattern is a compiled glob or regular expression.
+type managerPattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// NewManagerFilter compiles the include and exclude patterns of a
+// ManagerFilter.
+func NewManagerFilter(include, exclude []string) (*ManagerFilter, error) {
+	f := &ManagerFilter{}
+	var err error
+	if f.include, err = compileManagerPatterns(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compileManagerPatterns(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// compileManagerPatterns compiles globs and "/regexp/" patterns.
+func compileManagerPatterns(patterns []string) ([]managerPattern, error) {
+	var compiled []managerPattern
+	for _, p := range patterns {
+		if len(p) >= 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			re, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid manager pattern %q: %w", p, err)
+			}
+			compiled = append(compiled, managerPattern{re: re})
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid manager pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, managerPattern{glob: p})
+	}
+	return compiled, nil
+}
+
+// matches reports whether manager matches the pattern.
+func (p managerPattern) matches(manager string) bool {
+	if p.re != nil {
+		return p.re.MatchString(manager)
+	}
+	ok, _ := path.Match(p.glob, manager)
+	return ok
+}
+
+// Empty reports whether the filter keeps every entry. A nil filter is empty.
+func (f *ManagerFilter) Empty() bool {
+	return f == nil || len(f.include) == 0 && len(f.exclude) == 0
+}
+
+// Keep reports whether the filter keeps entries of manager.
+func (f *ManagerFilter) Keep(manager string) bool {
+	if f.Empty() {
+		return true
+	}
+	matchesAny := func(patterns []managerPattern) bool {
+		for _, p := range patterns {
+			if p.matches(manager) {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(f.include) == 0 || matchesAny(f.include)) && !matchesAny(f.exclude)
+}
+
+// Filter returns the entries the filter keeps, in order.
+func (f *ManagerFilter) Filter(entries []ManagedFieldsEntry) []ManagedFieldsEntry {
+	if f.Empty() {
+		return entries
+	}
+	var kept []ManagedFieldsEntry
+	for _, e := range entries {
+		if f.Keep(e.Manager) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// EntryFilter selects managedFields entries by manager, operation and
+// subresource. The zero value keeps every entry.
+type EntryFilter struct {
+	Managers *ManagerFilter
+
+	// Operation, when non-empty, is the operation (Apply or Update) of the
+	// entries kept, compared case-insensitively.
+	Operation string
+
+	// Subresource, when non-nil, is the subresource of the entries kept,
+	// with "" standing for the main resource.
+	Subresource *string
+
+	// After and Before, when non-zero, bound the Time of the entries kept:
+	// at or after After, and before Before.
+	After, Before time.Time
+}
+
+// Empty reports whether the filter keeps every entry.
+func (f EntryFilter) Empty() bool {
+	return f.Managers.Empty() && f.Operation == "" && f.Subresource == nil &&
+		f.After.IsZero() && f.Before.IsZero()
+}
+
+// Keep reports whether the filter keeps entry.
+func (f EntryFilter) Keep(entry ManagedFieldsEntry) bool {
+	if f.Operation != "" && !strings.EqualFold(entry.Operation, f.Operation) {
+		return false
+	}
+	if f.Subresource != nil && entry.Subresource != *f.Subresource {
+		return false
+	}
+	if !f.After.IsZero() && entry.Time.Before(f.After) || !f.Before.IsZero() && !entry.Time.Before(f.Before) {
+		return false
+	}
+	return f.Managers.Keep(entry.Manager)
+}
+
+// Filter returns the entries the filter keeps, in order.
+func (f EntryFilter) Filter(entries []ManagedFieldsEntry) []ManagedFieldsEntry {
+	if f.Empty() {
+		return entries
+	}
+	var kept []ManagedFieldsEntry
+	for _, e := range entries {
+		if f.Keep(e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
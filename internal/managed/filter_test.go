@@ -0,0 +1,116 @@
+package managed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagerFilter_Keep(t *testing.T) {
+	tests := []struct {
+		name             string
+		include, exclude []string
+		kept             []string
+	}{
+		{"empty", nil, nil, []string{"kubectl", "kube-controller-manager", "kubelet", "helm"}},
+		{"include glob", []string{"kube*"}, nil, []string{"kubectl", "kube-controller-manager", "kubelet"}},
+		{"exclude globs", nil, []string{"kube-controller-manager", "kubelet"}, []string{"kubectl", "helm"}},
+		{"include and exclude", []string{"kube*"}, []string{"kubelet"}, []string{"kubectl", "kube-controller-manager"}},
+		{"regexp", []string{"/^kube-|^helm$/"}, nil, []string{"kube-controller-manager", "helm"}},
+		{"exclude regexp", nil, []string{"/controller/"}, []string{"kubectl", "kubelet", "helm"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewManagerFilter(tt.include, tt.exclude)
+			require.NoError(t, err)
+			var kept []string
+			for _, m := range []string{"kubectl", "kube-controller-manager", "kubelet", "helm"} {
+				if f.Keep(m) {
+					kept = append(kept, m)
+				}
+			}
+			assert.Equal(t, tt.kept, kept)
+		})
+	}
+}
+
+func TestManagerFilter_Filter(t *testing.T) {
+	entries := []ManagedFieldsEntry{{Manager: "kubectl"}, {Manager: "kubelet"}, {Manager: "helm"}}
+
+	f, err := NewManagerFilter(nil, []string{"kubelet"})
+	require.NoError(t, err)
+	assert.Equal(t, []ManagedFieldsEntry{{Manager: "kubectl"}, {Manager: "helm"}}, f.Filter(entries))
+
+	f, err = NewManagerFilter([]string{"argocd"}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, f.Filter(entries))
+
+	var none *ManagerFilter
+	assert.True(t, none.Empty())
+	assert.Equal(t, entries, none.Filter(entries))
+}
+
+func TestNewManagerFilter_Invalid(t *testing.T) {
+	_, err := NewManagerFilter([]string{"[kube"}, nil)
+	assert.ErrorContains(t, err, `invalid manager pattern "[kube"`)
+
+	_, err = NewManagerFilter(nil, []string{"/(kube/"})
+	assert.ErrorContains(t, err, `invalid manager pattern "/(kube/"`)
+}
+
+func TestEntryFilter(t *testing.T) {
+	entries := []ManagedFieldsEntry{
+		{Manager: "helm", Operation: "Apply"},
+		{Manager: "kubectl", Operation: "Update"},
+		{Manager: "kube-controller-manager", Operation: "Update", Subresource: "status"},
+		{Manager: "hpa", Operation: "Update", Subresource: "scale"},
+	}
+	main, status := "", "status"
+	managers, err := NewManagerFilter([]string{"kube*"}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		filter EntryFilter
+		want   []string
+	}{
+		{"zero", EntryFilter{}, []string{"helm", "kubectl", "kube-controller-manager", "hpa"}},
+		{"operation", EntryFilter{Operation: "update"}, []string{"kubectl", "kube-controller-manager", "hpa"}},
+		{"main resource", EntryFilter{Subresource: &main}, []string{"helm", "kubectl"}},
+		{"status", EntryFilter{Subresource: &status}, []string{"kube-controller-manager"}},
+		{"managers and operation", EntryFilter{Managers: managers, Operation: "Update", Subresource: &main}, []string{"kubectl"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range tt.filter.Filter(entries) {
+				got = append(got, e.Manager)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	assert.True(t, EntryFilter{}.Empty())
+	assert.False(t, EntryFilter{Subresource: &main}.Empty())
+}
+
+func TestEntryFilter_Time(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	entries := []ManagedFieldsEntry{
+		{Manager: "helm", Time: now.Add(-48 * time.Hour)},
+		{Manager: "kubectl", Time: now.Add(-2 * time.Hour)},
+		{Manager: "hpa", Time: now.Add(-time.Minute)},
+	}
+	managers := func(f EntryFilter) []string {
+		var got []string
+		for _, e := range f.Filter(entries) {
+			got = append(got, e.Manager)
+		}
+		return got
+	}
+
+	assert.Equal(t, []string{"kubectl", "hpa"}, managers(EntryFilter{After: now.Add(-2 * time.Hour)}))
+	assert.Equal(t, []string{"helm"}, managers(EntryFilter{Before: now.Add(-2 * time.Hour)}))
+	assert.Equal(t, []string{"kubectl"}, managers(EntryFilter{After: now.Add(-3 * time.Hour), Before: now.Add(-time.Hour)}))
+}
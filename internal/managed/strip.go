@@ -1,6 +1,7 @@
 package managed
 
 import (
+	"github.com/ahmetb/kubectl-fields/internal/parser"
 	"go.yaml.in/yaml/v3"
 )
 
@@ -12,8 +13,8 @@ func StripManagedFields(root *yaml.Node) bool {
 		return false
 	}
 
-	metadataNode, ok := getMapValueNode(root, "metadata")
-	if !ok || metadataNode.Kind != yaml.MappingNode {
+	metadataNode := parser.MapValue(root, "metadata")
+	if metadataNode == nil || metadataNode.Kind != yaml.MappingNode {
 		return false
 	}
 
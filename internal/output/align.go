@@ -43,6 +43,45 @@ func splitInlineComment(line string) (content string, comment string, hasComment
 	return line[:idx], line[idx+1:], true
 }
 
+// splitJSONInlineComment is the JSON counterpart of splitInlineComment. It
+// splits a line at the first " // " that is outside of a JSON string literal,
+// so string values containing "//" (such as URLs) are never mistaken for
+// comments. Lines that consist only of a "//" comment return false.
+func splitJSONInlineComment(line string) (content string, comment string, hasComment bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if strings.HasPrefix(trimmed, "//") {
+		return line, "", false
+	}
+
+	inString, escaped := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case !inString && strings.HasPrefix(line[i:], " // "):
+			return line[:i], line[i+1:], true
+		}
+	}
+	return line, "", false
+}
+
+// commentSyntax describes how comments are written in the text being
+// formatted: the comment marker and the matching inline comment splitter.
+type commentSyntax struct {
+	marker string
+	split  func(line string) (content string, comment string, hasComment bool)
+}
+
+var (
+	yamlSyntax = commentSyntax{marker: "#", split: splitInlineComment}
+	jsonSyntax = commentSyntax{marker: "//", split: splitJSONInlineComment}
+)
+
 // AlignComments performs per-block alignment of inline comments.
 //
 // Consecutive lines with inline comments form a block. A line without an
@@ -57,11 +96,16 @@ func splitInlineComment(line string) (content string, comment string, hasComment
 // pass through unchanged. They are not considered inline comments and do not
 // participate in block formation.
 func AlignComments(text string) string {
+	return alignComments(text, yamlSyntax)
+}
+
+// alignComments implements AlignComments for the given comment syntax.
+func alignComments(text string, syntax commentSyntax) string {
 	lines := strings.Split(text, "\n")
 
 	parsed := make([]annotatedLine, len(lines))
 	for i, line := range lines {
-		content, comment, has := splitInlineComment(line)
+		content, comment, has := syntax.split(line)
 		parsed[i] = annotatedLine{
 			content:    content,
 			comment:    comment,
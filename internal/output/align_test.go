@@ -158,3 +158,37 @@ func spaces(n int) string {
 	}
 	return s
 }
+
+func TestSplitJSONInlineComment(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		wantContent string
+		wantComment string
+		wantHas     bool
+	}{
+		{"scalar with comment", `    "replicas": 3, // mgr (1h ago)`, `    "replicas": 3,`, "// mgr (1h ago)", true},
+		{"container with comment", `    "labels": { // mgr`, `    "labels": {`, "// mgr", true},
+		{"no comment", `    "replicas": 3,`, `    "replicas": 3,`, "", false},
+		{"slashes inside string", `    "url": "http://a // b"`, `    "url": "http://a // b"`, "", false},
+		{"escaped quote inside string", `    "s": "a \" // b", // mgr`, `    "s": "a \" // b",`, "// mgr", true},
+		{"head comment line", `    // mgr (5m ago)`, `    // mgr (5m ago)`, "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			content, comment, has := splitJSONInlineComment(tc.line)
+			assert.Equal(t, tc.wantHas, has)
+			assert.Equal(t, tc.wantContent, content)
+			assert.Equal(t, tc.wantComment, comment)
+		})
+	}
+}
+
+func TestAlignComments_JSONBlock(t *testing.T) {
+	input := "{\n    \"replicas\": 3, // mgr-a\n    \"image\": \"nginx\" // mgr-b\n}\n"
+
+	got := alignComments(input, jsonSyntax)
+
+	assert.Contains(t, got, `    "replicas": 3,    // mgr-a`)
+	assert.Contains(t, got, `    "image": "nginx"  // mgr-b`)
+}
@@ -3,11 +3,20 @@ package output
 import (
 	"os"
 	"strings"
+
+	"github.com/ahmetb/kubectl-fields/internal/annotate"
 )
 
 // ANSI escape sequence constants.
 const Reset = "\x1b[0m"
 
+// Dim is the ANSI code for faint text, used for notes rather than owners.
+const Dim = "\x1b[2m"
+
+// Inverse is the ANSI code for reverse video, used to highlight annotations
+// marked annotate.RecentMark.
+const Inverse = "\x1b[7m"
+
 // BrightPalette contains 8 visually distinct ANSI colors for manager name colorization.
 // Colors are assigned round-robin in encounter order.
 var BrightPalette = []string{
@@ -25,9 +34,11 @@ var BrightPalette = []string{
 // The first manager encountered gets color 0, the second gets color 1, etc.
 // The same manager always gets the same color within an invocation.
 type ColorManager struct {
-	palette    []string
-	assigned   map[string]string
-	nextIndex  int
+	palette   []string
+	assigned  map[string]string
+	nextIndex int
+	pending   map[string][]string // managers of Register comments not yet colored, by text
+	notes     map[string]int      // pending Note comments, by text
 }
 
 // NewColorManager creates a ColorManager with the default BrightPalette.
@@ -35,6 +46,8 @@ func NewColorManager() *ColorManager {
 	return &ColorManager{
 		palette:  BrightPalette,
 		assigned: make(map[string]string),
+		pending:  make(map[string][]string),
+		notes:    make(map[string]int),
 	}
 }
 
@@ -52,22 +65,106 @@ func (cm *ColorManager) ColorFor(managerName string) string {
 }
 
 // Wrap wraps text in the manager's assigned ANSI color code followed by reset.
+// Text marked annotate.RecentMark is also inverted.
 func (cm *ColorManager) Wrap(text, managerName string) string {
+	if strings.Contains(text, annotate.RecentMark) {
+		return cm.ColorFor(managerName) + Inverse + text + Reset
+	}
 	return cm.ColorFor(managerName) + text + Reset
 }
 
+// Note records that the next comment reading comment (without its "#" or
+// "//" marker) is a note, such as a document header naming its source file,
+// rather than an owner annotation, so that it is rendered Dim. Each Note
+// applies to one comment.
+func (cm *ColorManager) Note(comment string) {
+	cm.notes[comment]++
+}
+
+// isPendingNote reports whether the comment text s was recorded with Note,
+// consuming the record.
+func (cm *ColorManager) isPendingNote(s string) bool {
+	if cm.notes[s] == 0 {
+		return false
+	}
+	if cm.notes[s]--; cm.notes[s] == 0 {
+		delete(cm.notes, s)
+	}
+	return true
+}
+
+// wrapComment wraps a comment in the color of its manager, or in Dim when
+// it is a note: a comment recorded with Note or, unless registered, one that
+// annotate.IsNote recognizes, such as "# (unowned)". It reports false when
+// the comment has no manager.
+func (cm *ColorManager) wrapComment(comment string) (string, bool) {
+	s := strings.TrimPrefix(strings.TrimPrefix(comment, "# "), "// ")
+	if cm.isPendingNote(s) {
+		return Dim + comment + Reset, true
+	}
+	manager, ok := cm.registered(s)
+	if !ok {
+		if annotate.IsNote(s) {
+			return Dim + comment + Reset, true
+		}
+		manager = extractManagerName(comment)
+	}
+	if manager == "" {
+		return "", false
+	}
+	return cm.Wrap(comment, manager), true
+}
+
+// Register records that the next comment reading comment (without its "#"
+// or "//" marker) annotates a field of manager. It is used for comments laid
+// out by a custom format, whose manager extractManagerName cannot find.
+// Comments are registered in the order they are written, so that comments
+// reading the same for different managers each get their manager's color.
+// Each Register applies to one comment.
+func (cm *ColorManager) Register(comment, manager string) {
+	cm.pending[comment] = append(cm.pending[comment], manager)
+}
+
+// Reset drops the Register and Note records of comments that were never
+// written, such as those of documents the output omits.
+func (cm *ColorManager) Reset() {
+	clear(cm.pending)
+	clear(cm.notes)
+}
+
+// registered returns the manager registered first for the comment text s,
+// consuming the record.
+func (cm *ColorManager) registered(s string) (string, bool) {
+	managers := cm.pending[s]
+	if len(managers) == 0 {
+		return "", false
+	}
+	if len(managers) == 1 {
+		delete(cm.pending, s)
+	} else {
+		cm.pending[s] = managers[1:]
+	}
+	return managers[0], true
+}
+
 // extractManagerName extracts the manager name from a comment string.
 // The manager name is everything from start of the comment (after optional
-// "# " prefix) up to the first " /" (subresource) or " (" (timestamp) or
-// end of string.
+// "# " or "// " prefix) up to the first " /" (subresource), " (" (timestamp),
+// " + " (co-owner), " [" (mark such as "[changed]") or end of string. For
+// fields with co-owners this is the first (primary) owner.
 func extractManagerName(comment string) string {
 	s := comment
-	// Strip leading "# " if present
+	// Strip leading "# " or "// " if present
 	if strings.HasPrefix(s, "# ") {
 		s = s[2:]
+	} else if strings.HasPrefix(s, "// ") {
+		s = s[3:]
 	}
 
-	// Find first " /" (subresource delimiter) or " (" (timestamp delimiter)
+	// Cut at the first co-owner and at marks, then find first " /"
+	// (subresource delimiter) or " (" (timestamp delimiter)
+	s, _, _ = strings.Cut(s, " + ")
+	s, _, _ = strings.Cut(s, " [")
 	if idx := strings.Index(s, " /"); idx >= 0 {
 		return s[:idx]
 	}
@@ -121,6 +121,26 @@ func TestExtractManagerName(t *testing.T) {
 			comment:  "# manager (apply)",
 			expected: "manager",
 		},
+		{
+			name:     "with JSON comment prefix",
+			comment:  "// kube-controller-manager /status (1h ago)",
+			expected: "kube-controller-manager",
+		},
+		{
+			name:     "co-owners",
+			comment:  "# helm (2h ago) + kubectl (5m ago)",
+			expected: "helm",
+		},
+		{
+			name:     "with mark (hide mode)",
+			comment:  "# kubectl [inherited]",
+			expected: "kubectl",
+		},
+		{
+			name:     "co-owners (hide mode)",
+			comment:  "# helm + kubectl",
+			expected: "helm",
+		},
 	}
 
 	for _, tc := range tests {
@@ -9,9 +9,19 @@ import (
 // Alignment always runs (per user decision). Colorization runs only when
 // colorEnabled is true. The colorMgr may be nil when color is disabled.
 func FormatOutput(text string, colorEnabled bool, colorMgr *ColorManager) string {
-	aligned := AlignComments(text)
+	return formatOutput(text, colorEnabled, colorMgr, yamlSyntax)
+}
+
+// FormatJSONOutput is FormatOutput for JSON text with "//" comments.
+func FormatJSONOutput(text string, colorEnabled bool, colorMgr *ColorManager) string {
+	return formatOutput(text, colorEnabled, colorMgr, jsonSyntax)
+}
+
+// formatOutput implements FormatOutput for the given comment syntax.
+func formatOutput(text string, colorEnabled bool, colorMgr *ColorManager, syntax commentSyntax) string {
+	aligned := alignComments(text, syntax)
 	if colorEnabled && colorMgr != nil {
-		return Colorize(aligned, colorMgr)
+		return colorize(aligned, colorMgr, syntax)
 	}
 	return aligned
 }
@@ -25,38 +35,41 @@ func FormatOutput(text string, colorEnabled bool, colorMgr *ColorManager) string
 //
 // The "#" is included in the colored text per user decision.
 func Colorize(text string, cm *ColorManager) string {
+	return colorize(text, cm, yamlSyntax)
+}
+
+// colorize implements Colorize for the given comment syntax.
+func colorize(text string, cm *ColorManager, syntax commentSyntax) string {
 	lines := strings.Split(text, "\n")
 	result := make([]string, len(lines))
 
 	for i, line := range lines {
-		result[i] = colorizeLine(line, cm)
+		result[i] = colorizeLine(line, cm, syntax)
 	}
 
 	return strings.Join(result, "\n")
 }
 
 // colorizeLine applies color to a single line if it contains a comment.
-func colorizeLine(line string, cm *ColorManager) string {
+func colorizeLine(line string, cm *ColorManager, syntax commentSyntax) string {
 	// Try inline comment first: "content # comment"
-	content, comment, hasInline := splitInlineComment(line)
+	content, comment, hasInline := syntax.split(line)
 	if hasInline {
-		manager := extractManagerName(comment)
-		if manager != "" {
-			return content + " " + cm.Wrap(comment, manager)
+		if colored, ok := cm.wrapComment(comment); ok {
+			return content + " " + colored
 		}
 		return line
 	}
 
 	// Try above-mode comment: optional whitespace then "# ..."
 	trimmed := strings.TrimLeft(line, " \t")
-	if strings.HasPrefix(trimmed, "# ") {
+	if strings.HasPrefix(trimmed, syntax.marker+" ") {
 		// Find where the comment starts in the original line
-		commentStart := strings.Index(line, "#")
+		commentStart := strings.Index(line, syntax.marker)
 		prefix := line[:commentStart]
 		commentText := line[commentStart:]
-		manager := extractManagerName(commentText)
-		if manager != "" {
-			return prefix + cm.Wrap(commentText, manager)
+		if colored, ok := cm.wrapComment(commentText); ok {
+			return prefix + colored
 		}
 	}
 
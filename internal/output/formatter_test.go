@@ -61,6 +61,115 @@ func TestColorize_AboveComment(t *testing.T) {
 	assert.Equal(t, "  replicas: 3", lines[1])
 }
 
+func TestColorize_UnownedDim(t *testing.T) {
+	input := "uid: abc  # (unowned)\nreplicas: 3  # kubectl-apply (30m ago)"
+
+	cm := NewColorManager()
+	got := Colorize(input, cm)
+
+	lines := strings.Split(got, "\n")
+	assert.Equal(t, "uid: abc  "+Dim+"# (unowned)"+Reset, lines[0])
+	// The unowned marker does not use up a manager color.
+	assert.Contains(t, lines[1], BrightPalette[0]+"# kubectl-apply (30m ago)"+Reset)
+}
+
+func TestColorize_SchemaLabelDim(t *testing.T) {
+	input := "  ports:  # [list-type: map keys=[containerPort,protocol]]\nargs:  # kubectl (1h ago) [list-type: atomic]"
+
+	cm := NewColorManager()
+	got := Colorize(input, cm)
+
+	lines := strings.Split(got, "\n")
+	assert.Equal(t, "  ports:  "+Dim+"# [list-type: map keys=[containerPort,protocol]]"+Reset, lines[0])
+	assert.Contains(t, lines[1], BrightPalette[0]+"# kubectl (1h ago) [list-type: atomic]"+Reset)
+}
+
+func TestColorize_DocumentHeaderNotes(t *testing.T) {
+	input := "# Source: deploy.yaml\n# Leaves: 2 owned, 1 unowned\n\nreplicas: 3  # kubectl (1h ago)\n# Source: deploy.yaml"
+
+	cm := NewColorManager()
+	cm.Note("Source: deploy.yaml")
+	cm.Note("Leaves: 2 owned, 1 unowned")
+	got := Colorize(input, cm)
+
+	lines := strings.Split(got, "\n")
+	assert.Equal(t, Dim+"# Source: deploy.yaml"+Reset, lines[0])
+	assert.Equal(t, Dim+"# Leaves: 2 owned, 1 unowned"+Reset, lines[1])
+	// Headers do not use up a manager color.
+	assert.Equal(t, "replicas: 3  "+BrightPalette[0]+"# kubectl (1h ago)"+Reset, lines[3])
+	// Each note applies to one comment.
+	assert.Equal(t, BrightPalette[1]+"# Source: deploy.yaml"+Reset, lines[4])
+}
+
+func TestColorize_RecentInverted(t *testing.T) {
+	input := "replicas: 3  # hpa (5m ago) [recent]\nimage: nginx  # helm (2h ago)"
+
+	cm := NewColorManager()
+	got := Colorize(input, cm)
+
+	lines := strings.Split(got, "\n")
+	assert.Equal(t, "replicas: 3  "+BrightPalette[0]+Inverse+"# hpa (5m ago) [recent]"+Reset, lines[0])
+	assert.Equal(t, "image: nginx  "+BrightPalette[1]+"# helm (2h ago)"+Reset, lines[1])
+}
+
+func TestColorize_RegisteredComments(t *testing.T) {
+	input := "replicas: 3  # [hpa] [recent]\nimage: nginx  # [helm]\nports:  # [list-type: atomic]"
+
+	cm := NewColorManager()
+	cm.Register("[hpa] [recent]", "hpa")
+	cm.Register("[helm]", "helm")
+	got := Colorize(input, cm)
+
+	lines := strings.Split(got, "\n")
+	assert.Equal(t, "replicas: 3  "+BrightPalette[0]+Inverse+"# [hpa] [recent]"+Reset, lines[0])
+	assert.Equal(t, "image: nginx  "+BrightPalette[1]+"# [helm]"+Reset, lines[1])
+	assert.Equal(t, "ports:  "+Dim+"# [list-type: atomic]"+Reset, lines[2])
+}
+
+func TestColorize_RegisteredSameText(t *testing.T) {
+	// Comments reading the same get the color of the manager registered for
+	// each, in order.
+	input := "replicas: 3  # update\nimage: nginx  # update\nname: web  # update"
+
+	cm := NewColorManager()
+	cm.Register("update", "hpa")
+	cm.Register("update", "helm")
+	cm.Register("update", "hpa")
+	got := Colorize(input, cm)
+
+	lines := strings.Split(got, "\n")
+	assert.Equal(t, "replicas: 3  "+BrightPalette[0]+"# update"+Reset, lines[0])
+	assert.Equal(t, "image: nginx  "+BrightPalette[1]+"# update"+Reset, lines[1])
+	assert.Equal(t, "name: web  "+BrightPalette[0]+"# update"+Reset, lines[2])
+	assert.Empty(t, cm.pending)
+}
+
+func TestColorManager_Reset(t *testing.T) {
+	cm := NewColorManager()
+	cm.Register("[hpa]", "hpa")
+	cm.Note("Source: deploy.yaml")
+	cm.Reset()
+
+	assert.Empty(t, cm.pending)
+	assert.Empty(t, cm.notes)
+	_, ok := cm.registered("[hpa]")
+	assert.False(t, ok)
+}
+
+func TestColorize_BracketedManagerNotNote(t *testing.T) {
+	// Only unowned markers and semantic labels are notes: an unregistered
+	// bracketed annotation still gets a manager color.
+	input := "image: nginx  # [helm]\nuid: abc  # (unowned) [map-type: atomic]\nports:  # [list-type: atomic]"
+
+	cm := NewColorManager()
+	got := Colorize(input, cm)
+
+	lines := strings.Split(got, "\n")
+	assert.Equal(t, "image: nginx  "+BrightPalette[0]+"# [helm]"+Reset, lines[0])
+	assert.Equal(t, "uid: abc  "+Dim+"# (unowned) [map-type: atomic]"+Reset, lines[1])
+	assert.Equal(t, "ports:  "+Dim+"# [list-type: atomic]"+Reset, lines[2])
+}
+
 func TestColorize_NoComment(t *testing.T) {
 	input := "replicas: 3\nimage: nginx"
 
@@ -181,3 +290,16 @@ func TestFormatOutput_AlignmentIntegration_RealisticBlock(t *testing.T) {
 	assert.Equal(t, commentCols[0], commentCols[1], "lines 0 and 1 should have aligned comments")
 	assert.Equal(t, commentCols[1], commentCols[2], "lines 1 and 2 should have aligned comments")
 }
+
+func TestFormatJSONOutput_ColorEnabled(t *testing.T) {
+	input := "    \"replicas\": 3, // kubectl-apply (30m ago)\n    // helm (2h ago)\n    \"url\": \"http://x // y\"\n"
+
+	cm := NewColorManager()
+	got := FormatJSONOutput(input, true, cm)
+
+	lines := strings.Split(got, "\n")
+	assert.Equal(t, `    "replicas": 3,  `+BrightPalette[0]+"// kubectl-apply (30m ago)"+Reset, lines[0])
+	assert.Equal(t, "    "+BrightPalette[1]+"// helm (2h ago)"+Reset, lines[1])
+	// String values containing "//" are not comments.
+	assert.Equal(t, `    "url": "http://x // y"`, lines[2])
+}
@@ -0,0 +1,881 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Decoder reads documents from a YAML or JSON stream one at a time, so that
+// callers can process and write each document before the next one is read.
+//
+// Documents that carry a top-level "items" sequence (List or typed list
+// output such as PodList) are not returned whole: each item is returned as
+// its own DocumentNode as soon as it has been read. This keeps memory bounded
+// by the largest single object rather than the whole List. Because kubectl
+// prints "kind" after "items", a document is streamed this way when its kind,
+// printed before "items", is a list kind, or when it has no kind yet and its
+// apiVersion is "v1", as for kubectl's List (the core group has no other kind
+// with a top-level "items" field). Other documents, such as custom resources
+// printed with their keys in alphabetical order, are returned whole, as are
+// Lists that cannot be split (for example flow-style YAML); use
+// UnwrapListKind to unwrap them.
+//
+// A document that fails to parse is reported as a *DocumentError; decoding
+// can continue with the next document by calling Next again.
+type Decoder struct {
+	format Format
+	next   func() (Token, error)
+	yaml   *yamlStream // nil for JSON input
+	count  int         // documents and List items returned (or failed) so far
+}
+
+// TokenType identifies what a Token returned by Decoder.Next holds.
+type TokenType int
+
+const (
+	// DocumentToken holds a standalone DocumentNode.
+	DocumentToken TokenType = iota
+
+	// ListStartToken starts a streamed List. Node is a MappingNode with the
+	// envelope fields that precede "items" (possibly empty).
+	ListStartToken
+
+	// ListItemToken holds one item of a streamed List as a DocumentNode.
+	ListItemToken
+
+	// ListEndToken ends a streamed List. Node is a MappingNode with the
+	// envelope fields that follow "items" (possibly empty).
+	ListEndToken
+)
+
+// Token is a unit of decoded input returned by Decoder.Next.
+type Token struct {
+	Type TokenType
+	Node *yaml.Node
+
+	// Index is the 1-based position of a document or List item among all
+	// documents and items of the input (0 for envelope tokens).
+	Index int
+
+	// Line is the 1-based input line where the document, item or envelope
+	// starts.
+	Line int
+}
+
+// DocumentError reports a document (or List item) that could not be parsed.
+// Decoding can continue after it.
+type DocumentError struct {
+	Index int // 1-based position among all documents and List items
+	Line  int // 1-based input line of the error, or of the start of the document
+	Err   error
+}
+
+func (e *DocumentError) Error() string {
+	return fmt.Sprintf("document %d (line %d): %v", e.Index, e.Line, e.Err)
+}
+
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// yamlErrorLine matches the line number go-yaml puts in syntax errors.
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// yamlParseError wraps a go-yaml error for text that starts at input line
+// start, rewriting the line number in the message to be absolute.
+func yamlParseError(err error, start int) *DocumentError {
+	msg := err.Error()
+	if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		line := start + n - 1
+		return &DocumentError{Line: line, Err: fmt.Errorf("YAML parse error: line %d: %s", line, msg[len(m[0]):])}
+	}
+	return &DocumentError{Line: start, Err: fmt.Errorf("YAML parse error: %w", err)}
+}
+
+// NewDecoder creates a Decoder for r, detecting the input format with
+// DetectFormat.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	br := bufio.NewReader(r)
+	format, err := DetectFormat(br)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Decoder{format: format}
+	if format == FormatJSON {
+		d.next = newJSONStream(br).next
+	} else {
+		d.yaml = newYAMLStream(br)
+		d.next = d.yaml.next
+	}
+	return d, nil
+}
+
+// SetIdleTimeout makes the decoder return a pending YAML document once no
+// input has arrived for the given duration, instead of waiting for the next
+// "---" or EOF. This is meant for endless streams such as "kubectl get -w",
+// which prints the separator before an object rather than after it. If the
+// pending lines do not parse yet (the object is still being written), the
+// decoder keeps waiting for more input. JSON values are always returned as
+// soon as they are complete, so the timeout has no effect on JSON input.
+func (d *Decoder) SetIdleTimeout(timeout time.Duration) {
+	if d.yaml != nil {
+		d.yaml.idle = timeout
+	}
+}
+
+// Format returns the detected input format.
+func (d *Decoder) Format() Format {
+	return d.format
+}
+
+// Next returns the next token, including the ListStartToken and ListEndToken
+// envelope tokens around the items of a streamed List, so callers can
+// reproduce the List structure. It returns io.EOF when the input is
+// exhausted.
+func (d *Decoder) Next() (Token, error) {
+	tok, err := d.next()
+	var docErr *DocumentError
+	switch {
+	case errors.As(err, &docErr):
+		d.count++
+		docErr.Index = d.count
+	case err == nil && (tok.Type == DocumentToken || tok.Type == ListItemToken):
+		d.count++
+		tok.Index = d.count
+	}
+	return tok, err
+}
+
+// streamsItems reports whether the top-level "items" of a document with the
+// given kind and apiVersion, as far as they have been read, are streamed as
+// List items (see Decoder).
+func streamsItems(kind, apiVersion string) bool {
+	if kind == "" {
+		return apiVersion == "v1"
+	}
+	return isListKind(kind)
+}
+
+// isListKind reports whether kind names a Kubernetes list type: the generic
+// "List" used by kubectl, or a typed list such as "PodList" or
+// "PartialObjectMetadataList".
+func isListKind(kind string) bool {
+	return strings.HasSuffix(kind, "List")
+}
+
+// ItemKind returns the kind of the items of a typed list ("Pod" for a
+// "PodList"), given the root MappingNode of the list or of a streamed List
+// header. kubectl omits kind from the items of typed lists. It returns "" for
+// the generic "List" (whose items carry their own kind) and for other
+// objects.
+func ItemKind(list *yaml.Node) string {
+	kind := MapScalar(list, "kind")
+	if kind == "List" || !isListKind(kind) {
+		return ""
+	}
+	return strings.TrimSuffix(kind, "List")
+}
+
+// ---------------------------------------------------------------------------
+// YAML stream
+// ---------------------------------------------------------------------------
+
+// yamlState tracks where a yamlStream is within the current document.
+type yamlState int
+
+const (
+	yamlHeader     yamlState = iota // top-level fields before "items:"
+	yamlAwaitItems                  // saw "items:", waiting for the first "-"
+	yamlItems                       // inside the items block sequence
+	yamlTrailer                     // top-level fields after the items
+)
+
+// yamlStream splits a YAML stream into documents at "---" lines, and splits
+// the top-level "items:" block sequence of a document into individual items.
+// It works on lines, so only the current item (or, for other documents, the
+// current document) is held in memory.
+type yamlStream struct {
+	r     *bufio.Reader
+	queue []Token
+	done  bool
+
+	state      yamlState
+	doc        []string // lines of the current document up to "items:"
+	trailer    []string // lines of the current document after the items
+	item       []string // lines of the current item
+	itemIndent int      // column of the "-" introducing each item
+	kind       string   // top-level kind seen in the header, if any
+	apiVersion string   // top-level apiVersion seen in the header, if any
+	streamed   bool     // whether items of the current document were emitted
+
+	lineNo      int // input lines consumed so far
+	docLine     int // input line where the current document starts
+	itemLine    int // input line where the current item starts
+	trailerLine int // input line where the current trailer starts
+
+	idle  time.Duration   // see Decoder.SetIdleTimeout; 0 disables
+	lines chan lineResult // lines read in the background when idle > 0
+	stale bool            // the pending document failed to parse when idle
+}
+
+// lineResult is a line read by the background reader of an idle-flushing
+// yamlStream.
+type lineResult struct {
+	line string
+	err  error
+}
+
+// errIdle is returned by readLine when no input arrived within the idle
+// timeout while a document is pending.
+var errIdle = errors.New("input idle")
+
+func newYAMLStream(r *bufio.Reader) *yamlStream {
+	return &yamlStream{r: r}
+}
+
+func (s *yamlStream) next() (Token, error) {
+	for {
+		if len(s.queue) > 0 {
+			tok := s.queue[0]
+			s.queue = s.queue[1:]
+			return tok, nil
+		}
+		if s.done {
+			return Token{}, io.EOF
+		}
+
+		line, err := s.readLine()
+		if err == errIdle {
+			s.flushIdle()
+			continue
+		}
+		if line != "" {
+			if perr := s.consume(line); perr != nil {
+				return Token{}, perr
+			}
+		}
+		if err == io.EOF {
+			s.done = true
+			if perr := s.endDocument(); perr != nil {
+				return Token{}, perr
+			}
+			continue
+		}
+		if err != nil {
+			return Token{}, fmt.Errorf("reading input: %w", err)
+		}
+	}
+}
+
+// readLine returns the next input line. With an idle timeout, lines are read
+// in the background so that a pending document can be flushed when the input
+// goes quiet; errIdle reports that case.
+func (s *yamlStream) readLine() (string, error) {
+	if s.idle <= 0 {
+		return s.r.ReadString('\n')
+	}
+	if s.lines == nil {
+		s.lines = make(chan lineResult)
+		go func() {
+			for {
+				line, err := s.r.ReadString('\n')
+				s.lines <- lineResult{line, err}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	if !s.pending() {
+		res := <-s.lines
+		return res.line, res.err
+	}
+	timer := time.NewTimer(s.idle)
+	defer timer.Stop()
+	select {
+	case res := <-s.lines:
+		return res.line, res.err
+	case <-timer.C:
+		return "", errIdle
+	}
+}
+
+// pending reports whether a standalone document has been partly read and can
+// be flushed early when the input goes idle.
+func (s *yamlStream) pending() bool {
+	if s.stale || s.state != yamlHeader {
+		return false
+	}
+	for _, line := range s.doc {
+		if !isBlankOrComment(line) && !isDocumentStart(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// flushIdle queues the pending document if it parses. Otherwise the document
+// is left pending until more input arrives.
+func (s *yamlStream) flushIdle() {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(s.doc, "")), &doc); err != nil {
+		s.stale = true
+		return
+	}
+	// Parsing succeeded, so endDocument cannot fail.
+	_ = s.endDocument()
+}
+
+// consume feeds one input line (including its newline) to the splitter.
+func (s *yamlStream) consume(line string) error {
+	s.stale = false
+	s.lineNo++
+	if isDocumentStart(line) {
+		err := s.endDocument()
+		s.appendDoc(line)
+		return err
+	}
+	if isDocumentEnd(line) {
+		return s.endDocument()
+	}
+
+	switch s.state {
+	case yamlHeader:
+		s.appendDoc(line)
+		if k, ok := topLevelScalar(line, "kind"); ok {
+			s.kind = k
+		}
+		if v, ok := topLevelScalar(line, "apiVersion"); ok {
+			s.apiVersion = v
+		}
+		if isItemsKey(line) && streamsItems(s.kind, s.apiVersion) {
+			s.state = yamlAwaitItems
+		}
+
+	case yamlAwaitItems:
+		if isBlankOrComment(line) {
+			s.doc = append(s.doc, line)
+			return nil
+		}
+		indent := indentOf(line)
+		if isSequenceEntry(line, indent) {
+			err := s.startList()
+			s.state = yamlItems
+			s.itemIndent = indent
+			s.item, s.itemLine = []string{line}, s.lineNo
+			return err
+		}
+		s.state = yamlHeader
+		s.doc = append(s.doc, line)
+
+	case yamlItems:
+		if isBlankOrComment(line) || indentOf(line) > s.itemIndent {
+			s.item = append(s.item, line)
+			return nil
+		}
+		err := s.endItem()
+		if isSequenceEntry(line, s.itemIndent) {
+			s.item, s.itemLine = []string{line}, s.lineNo
+			return err
+		}
+		s.state = yamlTrailer
+		s.trailer, s.trailerLine = append(s.trailer, line), s.lineNo
+		return err
+
+	case yamlTrailer:
+		s.trailer = append(s.trailer, line)
+	}
+	return nil
+}
+
+// appendDoc adds a line to the current document, recording where the
+// document starts.
+func (s *yamlStream) appendDoc(line string) {
+	if len(s.doc) == 0 {
+		s.docLine = s.lineNo
+	}
+	s.doc = append(s.doc, line)
+}
+
+// startList queues the ListStartToken for the current document, built from
+// the header lines without the trailing "items:" key.
+func (s *yamlStream) startList() error {
+	lines := s.doc
+	for i := len(lines) - 1; i >= 0; i-- {
+		if isItemsKey(lines[i]) {
+			lines = lines[:i]
+			break
+		}
+	}
+	s.streamed = true
+	header, err := parseEnvelope(lines, s.docLine)
+	if err != nil {
+		header = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	s.queue = append(s.queue, Token{Type: ListStartToken, Node: header, Line: s.docLine})
+	return err
+}
+
+// endItem parses the buffered item lines and queues the item as a document.
+func (s *yamlStream) endItem() error {
+	if len(s.item) == 0 {
+		return nil
+	}
+	// Replace the "-" of the first line with a space. The item's content then
+	// sits at a consistent indentation and parses as a standalone document.
+	first := s.item[0]
+	s.item[0] = first[:s.itemIndent] + " " + first[s.itemIndent+1:]
+
+	text := strings.Join(s.item, "")
+	s.item = nil
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		return yamlParseError(err, s.itemLine)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!null"}}}
+	}
+	s.queue = append(s.queue, Token{Type: ListItemToken, Node: &doc, Line: s.itemLine})
+	return nil
+}
+
+// endDocument finishes the current document. Streamed Lists have already
+// emitted their items and only queue the ListEndToken; any other document is
+// parsed whole and queued. A streamed List always gets its ListEndToken, even
+// when its last item or trailer fails to parse.
+func (s *yamlStream) endDocument() error {
+	var itemErr error
+	if s.state == yamlItems {
+		itemErr = s.endItem()
+	}
+
+	lines, trailer, streamed := s.doc, s.trailer, s.streamed
+	s.doc, s.trailer, s.item, s.streamed, s.state = nil, nil, nil, false, yamlHeader
+	s.kind, s.apiVersion = "", ""
+
+	if streamed {
+		line := s.lineNo
+		if len(trailer) > 0 {
+			line = s.trailerLine
+		}
+		node, err := parseEnvelope(trailer, line)
+		if err != nil {
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		s.queue = append(s.queue, Token{Type: ListEndToken, Node: node, Line: line})
+		if itemErr != nil {
+			return itemErr
+		}
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(lines, "")), &doc); err != nil {
+		return yamlParseError(err, s.docLine)
+	}
+	if doc.Kind != 0 {
+		s.queue = append(s.queue, Token{Type: DocumentToken, Node: &doc, Line: s.docLine})
+	}
+	return nil
+}
+
+// parseEnvelope parses the top-level lines of a List envelope, starting at
+// input line start, into a MappingNode (empty when there are no fields).
+func parseEnvelope(lines []string, start int) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(lines, "")), &doc); err != nil {
+		return nil, yamlParseError(err, start)
+	}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 && doc.Content[0].Kind == yaml.MappingNode {
+		return doc.Content[0], nil
+	}
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+}
+
+// isDocumentStart reports whether line is a "---" document marker.
+func isDocumentStart(line string) bool {
+	return hasMarker(line, "---")
+}
+
+// isDocumentEnd reports whether line is a "..." document end marker.
+func isDocumentEnd(line string) bool {
+	return hasMarker(line, "...")
+}
+
+// hasMarker reports whether line starts with marker followed by whitespace
+// or end of line.
+func hasMarker(line, marker string) bool {
+	if !strings.HasPrefix(line, marker) {
+		return false
+	}
+	rest := line[len(marker):]
+	return rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' || rest[0] == '\r'
+}
+
+// isItemsKey reports whether line is a top-level "items:" key with its value
+// on the following lines.
+func isItemsKey(line string) bool {
+	rest, ok := strings.CutPrefix(line, "items:")
+	if !ok {
+		return false
+	}
+	rest = strings.TrimSpace(rest)
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+// topLevelScalar returns the plain scalar value of a top-level "key: value"
+// line.
+func topLevelScalar(line, key string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, key+":")
+	if !ok {
+		return "", false
+	}
+	return strings.Trim(strings.TrimSpace(rest), `"'`), true
+}
+
+// isBlankOrComment reports whether line is empty or only a comment.
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+// indentOf returns the number of leading spaces in line.
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// isSequenceEntry reports whether line starts a block sequence entry at
+// the given indentation.
+func isSequenceEntry(line string, indent int) bool {
+	if indentOf(line) != indent {
+		return false
+	}
+	rest := line[indent:]
+	return strings.HasPrefix(rest, "-") && hasMarker(rest, "-")
+}
+
+// ---------------------------------------------------------------------------
+// JSON stream
+// ---------------------------------------------------------------------------
+
+// jsonStream reads concatenated JSON objects token by token. The elements of
+// a top-level "items" array are decoded and returned one at a time; all other
+// values are read whole.
+//
+// After a parse error the stream skips ahead to the next line starting with
+// "{" (the start of a top-level object in kubectl output and in JSON lines)
+// and continues from there.
+type jsonStream struct {
+	src  *lineCounter  // the input, counting lines
+	dec  *json.Decoder // reads src, or what remains of it after an error
+	base int64         // input offset where dec started reading
+
+	inItems    bool       // inside a streamed "items" array
+	streamed   bool       // whether the current object is streamed as a List
+	pairs      []jsonPair // fields of the current object, in input order
+	kind       string     // kind of the current object, if seen
+	apiVersion string     // apiVersion of the current object, if seen
+	line       int        // input line where the current object starts
+	pendingEnd bool       // a streamed List was cut short by an error
+}
+
+// jsonPair is one field of a JSON object being reassembled.
+type jsonPair struct {
+	key string
+	raw json.RawMessage
+}
+
+func newJSONStream(r io.Reader) *jsonStream {
+	s := &jsonStream{src: &lineCounter{r: r}}
+	s.reset(s.src, 0)
+	return s
+}
+
+// reset starts decoding r, which begins at input offset base.
+func (s *jsonStream) reset(r io.Reader, base int64) {
+	s.dec = json.NewDecoder(r)
+	s.dec.UseNumber()
+	s.base = base
+}
+
+func (s *jsonStream) next() (Token, error) {
+	if s.pendingEnd {
+		// Close the List whose items were interrupted by a parse error, so
+		// callers keeping List envelopes stay balanced.
+		s.pendingEnd = false
+		return Token{Type: ListEndToken, Node: &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, Line: s.line}, nil
+	}
+
+	tok, err := s.read()
+	if err == nil || err == io.EOF {
+		return tok, err
+	}
+	docErr := &DocumentError{Line: s.errorLine(err), Err: err}
+	s.pendingEnd = s.streamed
+	s.inItems, s.streamed, s.pairs, s.kind, s.apiVersion = false, false, nil, "", ""
+	s.resync()
+	return Token{}, docErr
+}
+
+// read returns the next token from the current position.
+func (s *jsonStream) read() (Token, error) {
+	if s.inItems {
+		if s.dec.More() {
+			line := s.src.line(s.valueOffset())
+			var raw json.RawMessage
+			if err := s.dec.Decode(&raw); err != nil {
+				return Token{}, fmt.Errorf("JSON parse error: %w", err)
+			}
+			doc, err := jsonToNode(raw)
+			if err != nil {
+				return Token{}, err
+			}
+			return Token{Type: ListItemToken, Node: doc, Line: line}, nil
+		}
+		if _, err := s.dec.Token(); err != nil { // closing "]"
+			return Token{}, fmt.Errorf("JSON parse error: %w", err)
+		}
+		s.inItems = false
+		return s.finishObject()
+	}
+
+	tok, err := s.dec.Token()
+	if err == io.EOF {
+		return Token{}, io.EOF
+	}
+	if err != nil {
+		return Token{}, fmt.Errorf("JSON parse error: %w", err)
+	}
+	s.line = s.src.line(s.base + s.dec.InputOffset() - 1)
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return Token{}, fmt.Errorf("JSON parse error: expected object, got %v", tok)
+	}
+
+	s.pairs, s.kind, s.apiVersion, s.streamed = nil, "", "", false
+	return s.finishObject()
+}
+
+// valueOffset returns the input offset of the next value in the current
+// array, skipping the separator and whitespace before it.
+func (s *jsonStream) valueOffset() int64 {
+	offset := s.base + s.dec.InputOffset()
+	r, ok := s.dec.Buffered().(io.ByteReader)
+	if !ok {
+		return offset
+	}
+	for {
+		c, err := r.ReadByte()
+		if err != nil || !strings.ContainsRune(", \t\r\n", rune(c)) {
+			return offset
+		}
+		offset++
+	}
+}
+
+// errorLine returns the input line where a parse error occurred.
+func (s *jsonStream) errorLine(err error) int {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return s.src.line(s.base + syntaxErr.Offset)
+	}
+	return s.src.line(s.base + s.dec.InputOffset())
+}
+
+// resync skips the rest of the line where decoding stopped, and any further
+// lines up to the next one starting with "{", then restarts decoding there.
+func (s *jsonStream) resync() {
+	offset := s.base + s.dec.InputOffset()
+	r := bufio.NewReader(io.MultiReader(s.dec.Buffered(), s.src))
+	for {
+		line, err := r.ReadString('\n')
+		offset += int64(len(line))
+		if err != nil {
+			break
+		}
+		if b, err := r.Peek(1); err != nil || b[0] == '{' {
+			break
+		}
+	}
+	s.reset(r, offset)
+}
+
+// finishObject reads the remaining fields of the current object. It returns
+// a ListStartToken as soon as a non-empty, streamable "items" array starts;
+// otherwise it returns the reassembled object, or the ListEndToken when the
+// object was streamed as a List.
+func (s *jsonStream) finishObject() (Token, error) {
+	for s.dec.More() {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return Token{}, fmt.Errorf("JSON parse error: %w", err)
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return Token{}, fmt.Errorf("JSON parse error: expected object key, got %v", tok)
+		}
+
+		if key == "items" && !s.streamed && streamsItems(s.kind, s.apiVersion) {
+			tok, err := s.dec.Token()
+			if err != nil {
+				return Token{}, fmt.Errorf("JSON parse error: %w", err)
+			}
+			if delim, ok := tok.(json.Delim); ok && delim == '[' && s.dec.More() {
+				header, err := s.envelope()
+				if err != nil {
+					return Token{}, err
+				}
+				s.inItems, s.streamed = true, true
+				return Token{Type: ListStartToken, Node: header, Line: s.line}, nil
+			}
+			raw, err := s.rawFrom(tok)
+			if err != nil {
+				return Token{}, err
+			}
+			s.pairs = append(s.pairs, jsonPair{key: key, raw: raw})
+			continue
+		}
+
+		var raw json.RawMessage
+		if err := s.dec.Decode(&raw); err != nil {
+			return Token{}, fmt.Errorf("JSON parse error: %w", err)
+		}
+		switch key {
+		case "kind":
+			_ = json.Unmarshal(raw, &s.kind)
+		case "apiVersion":
+			_ = json.Unmarshal(raw, &s.apiVersion)
+		}
+		s.pairs = append(s.pairs, jsonPair{key: key, raw: raw})
+	}
+	if _, err := s.dec.Token(); err != nil { // closing "}"
+		return Token{}, fmt.Errorf("JSON parse error: %w", err)
+	}
+
+	if s.streamed {
+		trailer, err := s.envelope()
+		if err != nil {
+			return Token{}, err
+		}
+		s.streamed = false
+		return Token{Type: ListEndToken, Node: trailer, Line: s.line}, nil
+	}
+	doc, err := jsonToNode(assembleObject(s.pairs))
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Type: DocumentToken, Node: doc, Line: s.line}, nil
+}
+
+// envelope converts the fields collected so far into a MappingNode and
+// resets the collected fields.
+func (s *jsonStream) envelope() (*yaml.Node, error) {
+	doc, err := jsonToNode(assembleObject(s.pairs))
+	if err != nil {
+		return nil, err
+	}
+	s.pairs = nil
+	return doc.Content[0], nil
+}
+
+// rawFrom re-encodes a value whose first token has already been consumed.
+func (s *jsonStream) rawFrom(tok json.Token) (json.RawMessage, error) {
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return json.Marshal(tok)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(delim.String())
+	first := true
+	for s.dec.More() {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		if delim == '{' {
+			key, err := s.dec.Token()
+			if err != nil {
+				return nil, fmt.Errorf("JSON parse error: %w", err)
+			}
+			k, _ := json.Marshal(key)
+			buf.Write(k)
+			buf.WriteByte(':')
+		}
+		var raw json.RawMessage
+		if err := s.dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("JSON parse error: %w", err)
+		}
+		buf.Write(raw)
+	}
+	end, err := s.dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("JSON parse error: %w", err)
+	}
+	buf.WriteString(end.(json.Delim).String())
+	return buf.Bytes(), nil
+}
+
+// assembleObject rebuilds a JSON object from its fields, preserving order.
+func assembleObject(pairs []jsonPair) json.RawMessage {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, p := range pairs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(p.key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(p.raw)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// lineCounter is an io.Reader that records where the lines of its input
+// start, so that input offsets can be mapped to line numbers. Offsets must be
+// looked up in non-decreasing order; newlines before the last looked-up
+// offset are counted and then forgotten, keeping memory bounded.
+type lineCounter struct {
+	r         io.Reader
+	offset    int64   // bytes read so far
+	newlines  []int64 // offsets of newlines not yet forgotten
+	forgotten int     // newlines before newlines[0]
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			c.newlines = append(c.newlines, c.offset+int64(i))
+		}
+	}
+	c.offset += int64(n)
+	return n, err
+}
+
+// line returns the 1-based line containing the byte at offset.
+func (c *lineCounter) line(offset int64) int {
+	i := sort.Search(len(c.newlines), func(i int) bool { return c.newlines[i] >= offset })
+	c.forgotten += i
+	c.newlines = c.newlines[i:]
+	return c.forgotten + 1
+}
@@ -0,0 +1,603 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v3"
+)
+
+// decodeAll drains a Decoder created from input, returning documents and
+// the items of streamed Lists, whose envelopes are dropped.
+func decodeAll(t *testing.T, input string) ([]*yaml.Node, Format) {
+	t.Helper()
+	dec, err := NewDecoder(strings.NewReader(input))
+	require.NoError(t, err)
+
+	var docs []*yaml.Node
+	for {
+		tok, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if tok.Type != DocumentToken && tok.Type != ListItemToken {
+			continue
+		}
+		require.Equal(t, yaml.DocumentNode, tok.Node.Kind)
+		docs = append(docs, tok.Node)
+	}
+	return docs, dec.Format()
+}
+
+// docName returns metadata.name of a decoded document.
+func docName(t *testing.T, doc *yaml.Node) string {
+	t.Helper()
+	name := MapScalar(MapValue(doc.Content[0], "metadata"), "name")
+	require.NotEmpty(t, name)
+	return name
+}
+
+// encodeAll encodes documents with an Encoder, one call per document.
+func encodeAll(t *testing.T, docs []*yaml.Node, format Format) string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, format)
+	for _, doc := range docs {
+		require.NoError(t, enc.Encode(doc))
+	}
+	return buf.String()
+}
+
+func TestDecoder_MultiDocRoundTrip(t *testing.T) {
+	for _, path := range []string{
+		"../../testdata/roundtrip/multidoc.yaml",
+		"../../testdata/roundtrip/deployment.yaml",
+		"../../testdata/roundtrip/configmap.yaml",
+	} {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		docs, format := decodeAll(t, string(data))
+		assert.Equal(t, FormatYAML, format)
+		assert.Equal(t, string(data), encodeAll(t, docs, FormatYAML), "round-trip fidelity failed for %s", path)
+	}
+}
+
+func TestDecoder_YAMLListStreamsItems(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/roundtrip/list_kind.yaml")
+	require.NoError(t, err)
+
+	docs, _ := decodeAll(t, string(data))
+	require.Len(t, docs, 2)
+	assert.Equal(t, "cm-one", docName(t, docs[0]))
+	assert.Equal(t, "cm-two", docName(t, docs[1]))
+
+	// Items encode exactly like items unwrapped from a whole-document parse.
+	var whole yaml.Node
+	require.NoError(t, yaml.Unmarshal(data, &whole))
+	assert.Equal(t, encodeAll(t, UnwrapListKind(&whole), FormatYAML), encodeAll(t, docs, FormatYAML))
+}
+
+func TestDecoder_YAMLListKubectlOrder(t *testing.T) {
+	// kubectl prints items before kind; items contain block scalars with
+	// blank lines and column-0 comments must stay with their item.
+	input := `apiVersion: v1
+items:
+- apiVersion: v1
+  data:
+    script: |
+      echo one
+
+      echo two
+  kind: ConfigMap
+  metadata:
+    name: one
+# between items
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: two
+kind: List
+metadata:
+  resourceVersion: ""
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: three
+`
+	docs, _ := decodeAll(t, input)
+	require.Len(t, docs, 3)
+	assert.Equal(t, "one", docName(t, docs[0]))
+	assert.Equal(t, "two", docName(t, docs[1]))
+	assert.Equal(t, "three", docName(t, docs[2]))
+
+	data := MapValue(docs[0].Content[0], "data")
+	assert.Equal(t, "echo one\n\necho two\n", MapScalar(data, "script"))
+}
+
+func TestDecoder_YAMLListIndentedItems(t *testing.T) {
+	input := "kind: List\nitems:\n  - metadata:\n      name: one\n  - metadata:\n      name: two\n"
+	docs, _ := decodeAll(t, input)
+	require.Len(t, docs, 2)
+	assert.Equal(t, "one", docName(t, docs[0]))
+	assert.Equal(t, "two", docName(t, docs[1]))
+}
+
+func TestDecoder_YAMLNonListItemsNotStreamed(t *testing.T) {
+	// kind is known before items and is not a List: returned whole.
+	input := "kind: Widget\nitems:\n- a\n- b\n"
+	docs, _ := decodeAll(t, input)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "Widget", MapScalar(docs[0].Content[0], "kind"))
+}
+
+func TestDecoder_CustomResourceItemsBeforeKind(t *testing.T) {
+	// Custom resources print their keys alphabetically, so items comes
+	// before a kind that is not a list kind: the object is returned whole.
+	inputs := map[Format]string{
+		FormatYAML: "apiVersion: example.com/v1\nitems:\n- name: a\n  value: 1\nkind: Widget\nmetadata:\n  name: w\n",
+		FormatJSON: `{"apiVersion":"example.com/v1","items":[{"name":"a","value":1}],"kind":"Widget","metadata":{"name":"w"}}`,
+	}
+	for format, input := range inputs {
+		t.Run(string(format), func(t *testing.T) {
+			toks, errs := nextAll(t, input)
+			require.Empty(t, errs)
+			require.Len(t, toks, 1)
+			assert.Equal(t, DocumentToken, toks[0].Type)
+			assert.Equal(t, "w", docName(t, toks[0].Node))
+			assert.Equal(t, "Widget", MapScalar(toks[0].Node.Content[0], "kind"))
+			assert.Len(t, MapValue(toks[0].Node.Content[0], "items").Content, 1)
+		})
+	}
+}
+
+func TestDecoder_GroupTypedListItemsBeforeKindReturnedWhole(t *testing.T) {
+	input := "apiVersion: example.com/v1\nitems:\n- metadata:\n    name: a\n- metadata:\n    name: b\nkind: WidgetList\n"
+	toks, errs := nextAll(t, input)
+	require.Empty(t, errs)
+	require.Len(t, toks, 1)
+	assert.Equal(t, DocumentToken, toks[0].Type)
+	assert.Len(t, UnwrapListKind(toks[0].Node), 2)
+}
+
+func TestDecoder_YAMLFlowListReturnedWhole(t *testing.T) {
+	input := "kind: List\nitems: [{metadata: {name: one}}]\n"
+	docs, _ := decodeAll(t, input)
+	require.Len(t, docs, 1)
+	assert.Len(t, UnwrapListKind(docs[0]), 1)
+}
+
+func TestDecoder_EmptyInput(t *testing.T) {
+	docs, format := decodeAll(t, "")
+	assert.Empty(t, docs)
+	assert.Equal(t, FormatYAML, format)
+}
+
+func TestDecoder_YAMLParseError(t *testing.T) {
+	dec, err := NewDecoder(strings.NewReader("a: 1\n---\n:\n  - :\n    -"))
+	require.NoError(t, err)
+
+	_, err = dec.Next()
+	require.NoError(t, err)
+	_, err = dec.Next()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "YAML parse error")
+}
+
+func TestDecoder_JSONListStreamsItems(t *testing.T) {
+	input := `{
+    "apiVersion": "v1",
+    "items": [
+        {"kind": "ConfigMap", "metadata": {"name": "one"}},
+        {"kind": "ConfigMap", "metadata": {"name": "two"}}
+    ],
+    "kind": "List",
+    "metadata": {"resourceVersion": ""}
+}
+{"kind": "Secret", "metadata": {"name": "three", "labels": {"b": "1", "a": "2"}}}`
+
+	docs, format := decodeAll(t, input)
+	assert.Equal(t, FormatJSON, format)
+	require.Len(t, docs, 3)
+	assert.Equal(t, "one", docName(t, docs[0]))
+	assert.Equal(t, "two", docName(t, docs[1]))
+	assert.Equal(t, "three", docName(t, docs[2]))
+
+	// Field order of reassembled objects is preserved.
+	assert.Equal(t, "kind: Secret\nmetadata:\n  name: three\n  labels:\n    b: \"1\"\n    a: \"2\"\n",
+		encodeAll(t, docs[2:], FormatYAML))
+}
+
+func TestDecoder_JSONEmptyListReturnedWhole(t *testing.T) {
+	docs, _ := decodeAll(t, `{"apiVersion":"v1","items":[],"kind":"List"}`)
+	require.Len(t, docs, 1)
+	assert.Empty(t, UnwrapListKind(docs[0]))
+}
+
+func TestDecoder_JSONNonListItemsNotStreamed(t *testing.T) {
+	docs, _ := decodeAll(t, `{"kind":"Widget","items":[1,{"a":[true,null]}],"size":1.50}`)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "kind: Widget\nitems:\n- 1\n- a:\n  - true\n  - null\nsize: 1.50\n",
+		encodeAll(t, docs, FormatYAML))
+}
+
+func TestDecoder_JSONRoundTrip(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/1_deployment.json")
+	require.NoError(t, err)
+
+	docs, _ := decodeAll(t, string(data)+string(data))
+	require.Len(t, docs, 2)
+	assert.Equal(t, string(data)+string(data), encodeAll(t, docs, FormatJSON))
+}
+
+func TestDecoder_JSONParseError(t *testing.T) {
+	dec, err := NewDecoder(strings.NewReader(`{"kind": "Pod"} {"kind": `))
+	require.NoError(t, err)
+
+	_, err = dec.Next()
+	require.NoError(t, err)
+	_, err = dec.Next()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JSON parse error")
+}
+
+func TestDecoder_TypedListStreamsItems(t *testing.T) {
+	tests := []struct {
+		path  string
+		names []string
+	}{
+		{"../../testdata/lists/pod_list.yaml", []string{"web-0", "web-1"}},
+		{"../../testdata/lists/partial_metadata_list.json", []string{"nginx-deployment", "redis"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			data, err := os.ReadFile(tc.path)
+			require.NoError(t, err)
+
+			docs, _ := decodeAll(t, string(data))
+			require.Len(t, docs, len(tc.names))
+			for i, name := range tc.names {
+				assert.Equal(t, name, docName(t, docs[i]))
+			}
+		})
+	}
+}
+
+// reencodeTokens decodes input token by token and re-encodes it with the
+// Encoder's List methods, keeping List envelopes.
+func reencodeTokens(t *testing.T, input string, format Format) (string, []TokenType) {
+	t.Helper()
+	dec, err := NewDecoder(strings.NewReader(input))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	var types []TokenType
+	enc := NewEncoder(&buf, format)
+	for {
+		tok, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		types = append(types, tok.Type)
+
+		switch tok.Type {
+		case DocumentToken:
+			require.NoError(t, enc.Encode(tok.Node))
+		case ListStartToken:
+			require.NoError(t, enc.BeginList(tok.Node))
+		case ListItemToken:
+			require.NoError(t, enc.EncodeItem(tok.Node))
+		case ListEndToken:
+			require.NoError(t, enc.EndList(tok.Node))
+		}
+	}
+	return buf.String(), types
+}
+
+func TestDecoder_NextListTokens(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/roundtrip/list_kind.yaml")
+	require.NoError(t, err)
+
+	input := "kind: Secret\n---\n" + string(data)
+	got, types := reencodeTokens(t, input, FormatYAML)
+	assert.Equal(t, []TokenType{DocumentToken, ListStartToken, ListItemToken, ListItemToken, ListEndToken}, types)
+	assert.Equal(t, input, got)
+}
+
+func TestEncoder_KeepListMatchesWholeDocument(t *testing.T) {
+	// Lists re-encoded item by item must match encoding the whole List at
+	// once, for kubectl's field order (items before kind) and both formats.
+	input := `apiVersion: v1
+items:
+- apiVersion: v1
+  data:
+    key: val1
+  kind: ConfigMap
+  metadata:
+    name: cm-one
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    labels:
+      app: two
+    name: cm-two
+kind: List
+metadata:
+  resourceVersion: ""
+`
+	var whole yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(input), &whole))
+
+	for _, format := range []Format{FormatYAML, FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var want bytes.Buffer
+			require.NoError(t, NewEncoder(&want, format).Encode(&whole))
+
+			got, _ := reencodeTokens(t, input, format)
+			assert.Equal(t, want.String(), got)
+
+			// The JSON rendering streams back identically as well.
+			if format == FormatJSON {
+				again, _ := reencodeTokens(t, got, FormatJSON)
+				assert.Equal(t, got, again)
+			}
+		})
+	}
+}
+
+func TestEncoder_KeepListItemComments(t *testing.T) {
+	// Inline comments inside items survive the extra indentation.
+	item := parseDocument(t, "metadata:\n  name: one\n")
+	item.Content[0].Content[1].Content[1].LineComment = "mgr"
+
+	header := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	trailer := parseDocument(t, "kind: List\n")
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, FormatYAML)
+	require.NoError(t, enc.BeginList(header))
+	require.NoError(t, enc.EncodeItem(item))
+	require.NoError(t, enc.EndList(trailer.Content[0]))
+	assert.Equal(t, "items:\n- metadata:\n    name: one # mgr\nkind: List\n", buf.String())
+}
+
+func TestEncoder_EmptyList(t *testing.T) {
+	header := parseDocument(t, "apiVersion: v1\n")
+	trailer := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+
+	var yamlBuf, jsonBuf bytes.Buffer
+	for _, c := range []struct {
+		buf    *bytes.Buffer
+		format Format
+	}{{&yamlBuf, FormatYAML}, {&jsonBuf, FormatJSON}} {
+		enc := NewEncoder(c.buf, c.format)
+		require.NoError(t, enc.BeginList(header.Content[0]))
+		require.NoError(t, enc.EndList(trailer))
+	}
+	assert.Equal(t, "apiVersion: v1\nitems: []\n", yamlBuf.String())
+	assert.Equal(t, "{\n    \"apiVersion\": \"v1\",\n    \"items\": []\n}\n", jsonBuf.String())
+}
+
+func TestDecoder_IdleTimeoutFlushesPendingDocument(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	go func() {
+		_, _ = io.WriteString(pw, "kind: Pod\nmetadata:\n  name: one\n")
+	}()
+	dec, err := NewDecoder(pr)
+	require.NoError(t, err)
+	dec.SetIdleTimeout(10 * time.Millisecond)
+
+	// The first document is returned without a following "---" or EOF.
+	tok, err := dec.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "one", docName(t, tok.Node))
+
+	go func() {
+		_, _ = io.WriteString(pw, "---\nkind: Pod\nmetadata:\n  name: two\n")
+	}()
+	tok, err = dec.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "two", docName(t, tok.Node))
+}
+
+func TestDecoder_IdleTimeoutWaitsForIncompleteDocument(t *testing.T) {
+	pr, pw := io.Pipe()
+
+	go func() {
+		_, _ = io.WriteString(pw, "kind: Pod\nmetadata: {name: one,\n")
+		time.Sleep(50 * time.Millisecond)
+		_, _ = io.WriteString(pw, "  namespace: default}\n")
+		pw.Close()
+	}()
+	dec, err := NewDecoder(pr)
+	require.NoError(t, err)
+	dec.SetIdleTimeout(5 * time.Millisecond)
+
+	tok, err := dec.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "one", docName(t, tok.Node))
+
+	_, err = dec.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+// nextAll drains a Decoder, collecting tokens and document errors.
+func nextAll(t *testing.T, input string) ([]Token, []*DocumentError) {
+	t.Helper()
+	dec, err := NewDecoder(strings.NewReader(input))
+	require.NoError(t, err)
+
+	var toks []Token
+	var errs []*DocumentError
+	for {
+		tok, err := dec.Next()
+		if err == io.EOF {
+			return toks, errs
+		}
+		if err != nil {
+			var docErr *DocumentError
+			require.ErrorAs(t, err, &docErr)
+			errs = append(errs, docErr)
+			continue
+		}
+		toks = append(toks, tok)
+	}
+}
+
+func TestDecoder_TokenIndexAndLine(t *testing.T) {
+	input := `kind: Pod
+metadata:
+  name: one
+---
+kind: List
+items:
+- metadata:
+    name: two
+- metadata:
+    name: three
+`
+	toks, errs := nextAll(t, input)
+	require.Empty(t, errs)
+	require.Len(t, toks, 5)
+
+	assert.Equal(t, DocumentToken, toks[0].Type)
+	assert.Equal(t, 1, toks[0].Index)
+	assert.Equal(t, 1, toks[0].Line)
+
+	assert.Equal(t, ListStartToken, toks[1].Type)
+	assert.Equal(t, 0, toks[1].Index)
+	assert.Equal(t, 4, toks[1].Line)
+
+	assert.Equal(t, 2, toks[2].Index)
+	assert.Equal(t, 7, toks[2].Line)
+	assert.Equal(t, 3, toks[3].Index)
+	assert.Equal(t, 9, toks[3].Line)
+	assert.Equal(t, ListEndToken, toks[4].Type)
+}
+
+func TestDecoder_YAMLContinuesAfterError(t *testing.T) {
+	input := `metadata:
+  name: one
+---
+metadata:
+  name: a: b
+---
+kind: List
+items:
+- metadata:
+    name: two
+- metadata: {name: [bad}
+- metadata:
+    name: three
+kind: List
+`
+	toks, errs := nextAll(t, input)
+
+	require.Len(t, errs, 2)
+	assert.Equal(t, 2, errs[0].Index)
+	assert.Equal(t, 5, errs[0].Line)
+	assert.Contains(t, errs[0].Error(), "document 2 (line 5): YAML parse error: line 5:")
+	assert.Equal(t, 4, errs[1].Index)
+	assert.Equal(t, 11, errs[1].Line)
+
+	var names []string
+	var types []TokenType
+	for _, tok := range toks {
+		types = append(types, tok.Type)
+		if tok.Type == DocumentToken || tok.Type == ListItemToken {
+			names = append(names, docName(t, tok.Node))
+		}
+	}
+	assert.Equal(t, []string{"one", "two", "three"}, names)
+	assert.Equal(t, []TokenType{DocumentToken, ListStartToken, ListItemToken, ListItemToken, ListEndToken}, types)
+	assert.Equal(t, 5, toks[3].Index)
+}
+
+func TestDecoder_JSONResyncsAfterError(t *testing.T) {
+	input := `{"metadata": {"name": "one"}}
+{"metadata": {"name": "one-and-a-half"},, "x": 1}
+{"metadata": {"name": "two"}}
+`
+	toks, errs := nextAll(t, input)
+	require.Len(t, errs, 1)
+	assert.Equal(t, 2, errs[0].Index)
+	assert.Equal(t, 2, errs[0].Line)
+	assert.Contains(t, errs[0].Error(), "JSON parse error")
+
+	require.Len(t, toks, 2)
+	assert.Equal(t, "one", docName(t, toks[0].Node))
+	assert.Equal(t, "two", docName(t, toks[1].Node))
+	assert.Equal(t, 3, toks[1].Index)
+	assert.Equal(t, 3, toks[1].Line)
+}
+
+func TestDecoder_JSONErrorInsideListClosesList(t *testing.T) {
+	input := `{
+    "apiVersion": "v1",
+    "items": [
+        {"metadata": {"name": "one"}},
+        {"metadata": {"name": tw}}
+    ],
+    "kind": "List"
+}
+{"metadata": {"name": "three"}}
+`
+	toks, errs := nextAll(t, input)
+	require.Len(t, errs, 1)
+	assert.Equal(t, 5, errs[0].Line)
+
+	var types []TokenType
+	for _, tok := range toks {
+		types = append(types, tok.Type)
+	}
+	assert.Equal(t, []TokenType{ListStartToken, ListItemToken, ListEndToken, DocumentToken}, types)
+	assert.Equal(t, 4, toks[1].Line)
+	assert.Equal(t, "three", docName(t, toks[3].Node))
+	assert.Equal(t, 9, toks[3].Line)
+}
+
+func TestEncoder_DocumentHeadComment(t *testing.T) {
+	// Written like the head comment of a List, without a blank line.
+	doc := parseDocument(t, "apiVersion: v1\n")
+	doc.HeadComment = "Source: a.yaml\nsecond"
+
+	var buf bytes.Buffer
+	require.NoError(t, NewEncoder(&buf, FormatYAML).Encode(doc))
+	assert.Equal(t, "# Source: a.yaml\n# second\napiVersion: v1\n", buf.String())
+	assert.Equal(t, "Source: a.yaml\nsecond", doc.HeadComment)
+}
+
+func TestEncoder_BeginListHeadComment(t *testing.T) {
+	header := parseDocument(t, "apiVersion: v1\n")
+	empty := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+
+	for _, c := range []struct {
+		format Format
+		header *yaml.Node
+		want   string
+	}{
+		{FormatYAML, header.Content[0], "# Source: a.yaml\napiVersion: v1\nitems: []\n"},
+		{FormatYAML, empty, "# Source: a.yaml\nitems: []\n"},
+		{FormatJSON, header.Content[0], "// Source: a.yaml\n{\n    \"apiVersion\": \"v1\",\n    \"items\": []\n}\n"},
+	} {
+		h := *c.header
+		h.HeadComment = "Source: a.yaml"
+
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf, c.format)
+		require.NoError(t, enc.BeginList(&h))
+		require.NoError(t, enc.EndList(empty))
+		assert.Equal(t, c.want, buf.String())
+	}
+}
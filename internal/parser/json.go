@@ -0,0 +1,205 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// jsonToNode converts a single raw JSON value into a DocumentNode. JSON is
+// parsed by the YAML decoder (JSON is a subset of YAML), after which flow and
+// quoting styles are cleared so that YAML output looks like kubectl's
+// block-style output rather than inline JSON.
+func jsonToNode(raw json.RawMessage) (*yaml.Node, error) {
+	// Compact first: JSON allows tab indentation, which YAML rejects.
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return nil, fmt.Errorf("JSON parse error: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(compact.Bytes(), &doc); err != nil {
+		return nil, fmt.Errorf("JSON parse error: %w", err)
+	}
+	clearStyle(&doc)
+	return &doc, nil
+}
+
+// clearStyle resets the presentation style of a node tree so the YAML encoder
+// picks kubectl-compatible styles (block collections, plain or quoted scalars
+// as needed).
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// EncodeJSONDocuments writes all documents to the writer as indented JSON,
+// one value per document, using the same 4-space indentation as
+// "kubectl get -o json".
+//
+// Comments attached to nodes (annotations) are rendered as "//" comments, so
+// annotated output is JSONC rather than strict JSON. Inline comments (LineComment)
+// follow the value on the same line; head comments are written on their own
+// line above the field.
+func EncodeJSONDocuments(w io.Writer, docs []*yaml.Node) error {
+	bw := bufio.NewWriter(w)
+	for _, doc := range docs {
+		if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+			continue
+		}
+		jw := &jsonWriter{w: bw}
+		jw.headComment(doc.HeadComment, 0)
+		jw.value(doc.Content[0], 0, "", "")
+		if jw.err != nil {
+			return fmt.Errorf("JSON encode error: %w", jw.err)
+		}
+	}
+	return bw.Flush()
+}
+
+// jsonIndent is the indentation unit used by EncodeJSONDocuments.
+const jsonIndent = "    "
+
+// jsonWriter renders a yaml.Node tree as indented JSON. The first write error
+// is recorded and all subsequent writes become no-ops.
+type jsonWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (j *jsonWriter) write(s string) {
+	if j.err != nil {
+		return
+	}
+	_, j.err = j.w.WriteString(s)
+}
+
+// headComment writes each line of a head comment on its own line at depth.
+func (j *jsonWriter) headComment(comment string, depth int) {
+	if comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		j.write(strings.Repeat(jsonIndent, depth) + "// " + stripCommentMarker(line) + "\n")
+	}
+}
+
+// value writes a node whose first line has already been indented (or follows
+// a "key": prefix). trailer is "," or "" depending on whether more siblings
+// follow. keyComment is the LineComment of the owning mapping key, if any.
+func (j *jsonWriter) value(node *yaml.Node, depth int, trailer string, keyComment string) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	comment := node.LineComment
+	if comment == "" {
+		comment = keyComment
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			j.write("{}" + trailer + inlineComment(comment) + "\n")
+			return
+		}
+		j.write("{" + inlineComment(comment) + "\n")
+		j.fields(node, depth+1, false)
+		j.write(strings.Repeat(jsonIndent, depth) + "}" + trailer + "\n")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			j.write("[]" + trailer + inlineComment(comment) + "\n")
+			return
+		}
+		j.write("[" + inlineComment(comment) + "\n")
+		for i, item := range node.Content {
+			j.headComment(item.HeadComment, depth+1)
+			j.write(strings.Repeat(jsonIndent, depth+1))
+			j.value(item, depth+1, separator(i+1 < len(node.Content)), "")
+		}
+		j.write(strings.Repeat(jsonIndent, depth) + "]" + trailer + "\n")
+	default:
+		j.write(scalarJSON(node) + trailer + inlineComment(comment) + "\n")
+	}
+}
+
+// fields writes the key/value pairs of a mapping at depth, one per line.
+// When more is true, the last pair is also followed by a separator because
+// the caller writes further fields after it.
+func (j *jsonWriter) fields(node *yaml.Node, depth int, more bool) {
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		j.headComment(key.HeadComment, depth)
+		j.write(strings.Repeat(jsonIndent, depth) + quoteJSON(key.Value) + ": ")
+		j.value(val, depth, separator(more || i+2 < len(node.Content)), key.LineComment)
+	}
+}
+
+// separator returns the JSON element separator when more siblings follow.
+func separator(more bool) string {
+	if more {
+		return ","
+	}
+	return ""
+}
+
+// inlineComment renders a LineComment as a trailing "//" comment.
+func inlineComment(comment string) string {
+	if comment == "" {
+		return ""
+	}
+	// JSON output has no multi-line inline comments; join lines instead.
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		lines[i] = stripCommentMarker(line)
+	}
+	return " // " + strings.Join(lines, "; ")
+}
+
+// stripCommentMarker removes a leading YAML "#" marker (and one following
+// space) from a comment line, as go-yaml preserves it for parsed comments.
+func stripCommentMarker(line string) string {
+	if rest, ok := strings.CutPrefix(line, "#"); ok {
+		return strings.TrimPrefix(rest, " ")
+	}
+	return line
+}
+
+// scalarJSON renders a scalar node as a JSON literal based on its resolved
+// tag. Values that have no JSON representation are rendered as strings.
+func scalarJSON(node *yaml.Node) string {
+	switch node.ShortTag() {
+	case "!!str", "!!binary", "!!timestamp":
+		return quoteJSON(node.Value)
+	case "!!null":
+		return "null"
+	}
+
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return quoteJSON(node.Value)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return quoteJSON(node.Value)
+	}
+	return string(b)
+}
+
+// quoteJSON returns s as a JSON string literal without HTML escaping.
+func quoteJSON(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ---------------------------------------------------------------------------
+// DetectFormat tests
+// ---------------------------------------------------------------------------
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Format
+	}{
+		{"json object", `{"kind":"Pod"}`, FormatJSON},
+		{"json with leading whitespace", "\n  \t{\"kind\":\"Pod\"}", FormatJSON},
+		{"yaml mapping", "kind: Pod\n", FormatYAML},
+		{"yaml document marker", "---\nkind: Pod\n", FormatYAML},
+		{"empty input", "", FormatYAML},
+		{"whitespace only", "  \n", FormatYAML},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			br := bufio.NewReader(strings.NewReader(tc.input))
+			got, err := DetectFormat(br)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+
+			// Detection must not consume input.
+			rest, err := io.ReadAll(br)
+			require.NoError(t, err)
+			assert.Equal(t, tc.input, string(rest))
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// JSON decoding tests
+// ---------------------------------------------------------------------------
+
+func TestDecoder_JSONConcatenated(t *testing.T) {
+	input := "{\"kind\":\"ConfigMap\",\"metadata\":{\"name\":\"one\"}}\n" +
+		"{\"kind\":\"ConfigMap\",\"metadata\":{\"name\":\"two\"}}{\"kind\":\"Secret\"}"
+	docs, format := decodeAll(t, input)
+	assert.Equal(t, FormatJSON, format)
+	require.Len(t, docs, 3)
+
+	assert.Equal(t, "Secret", MapScalar(docs[2].Content[0], "kind"))
+}
+
+func TestDecoder_JSONStylesCleared(t *testing.T) {
+	// JSON input must encode as block-style YAML, with strings that would
+	// otherwise change type still quoted.
+	input := `{"data":{"enabled":"true","port":"80","count":3,"empty":{}},"list":["a"]}`
+	docs, _ := decodeAll(t, input)
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeDocuments(&buf, docs))
+	assert.Equal(t, `data:
+  enabled: "true"
+  port: "80"
+  count: 3
+  empty: {}
+list:
+- a
+`, buf.String())
+}
+
+func TestDecoder_JSONMatchesYAML(t *testing.T) {
+	// The JSON and YAML fixtures describe the same object, so encoding the
+	// parsed JSON as YAML must reproduce the YAML fixture exactly.
+	jsonData, err := os.ReadFile("../../testdata/1_deployment.json")
+	require.NoError(t, err)
+	yamlData, err := os.ReadFile("../../testdata/1_deployment.yaml")
+	require.NoError(t, err)
+
+	docs, _ := decodeAll(t, string(jsonData))
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeDocuments(&buf, docs))
+	assert.Equal(t, string(yamlData), buf.String())
+}
+
+// ---------------------------------------------------------------------------
+// EncodeJSONDocuments tests
+// ---------------------------------------------------------------------------
+
+func TestRoundTrip_JSON(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/1_deployment.json")
+	require.NoError(t, err)
+
+	docs, _ := decodeAll(t, string(data))
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeJSONDocuments(&buf, docs))
+	assert.Equal(t, string(data), buf.String())
+}
+
+func TestEncodeJSONDocuments_FromYAML(t *testing.T) {
+	input := "name: test\nreplicas: 3\nratio: 0.5\nenabled: true\nnothing: null\nport: \"80\"\nitems:\n- a\n- b\nempty: []\n"
+	docs, _ := decodeAll(t, input)
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeJSONDocuments(&buf, docs))
+	assert.Equal(t, `{
+    "name": "test",
+    "replicas": 3,
+    "ratio": 0.5,
+    "enabled": true,
+    "nothing": null,
+    "port": "80",
+    "items": [
+        "a",
+        "b"
+    ],
+    "empty": []
+}
+`, buf.String())
+}
+
+func TestEncodeJSONDocuments_Comments(t *testing.T) {
+	input := "labels:\n  app: nginx\ndata: {}\nlist:\n- a\n"
+	docs, _ := decodeAll(t, input)
+
+	root := docs[0].Content[0]
+	root.Content[0].LineComment = "mgr-a (1h ago)"     // container key
+	root.Content[1].Content[1].LineComment = "mgr-b"   // scalar value
+	root.Content[3].LineComment = "mgr-c"              // flow-empty value
+	root.Content[5].Content[0].HeadComment = "mgr-d"   // set item, above
+	root.Content[4].HeadComment = "# already prefixed" // key, above
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeJSONDocuments(&buf, docs))
+	assert.Equal(t, `{
+    "labels": { // mgr-a (1h ago)
+        "app": "nginx" // mgr-b
+    },
+    "data": {}, // mgr-c
+    // already prefixed
+    "list": [
+        // mgr-d
+        "a"
+    ]
+}
+`, buf.String())
+}
+
+func TestEncodeJSON_HeadCommentIndentation(t *testing.T) {
+	docs, _ := decodeAll(t, "a: 1\n")
+	docs[0].HeadComment = "header\n  indented"
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeJSONDocuments(&buf, docs))
+	assert.Equal(t, "// header\n//   indented\n{\n    \"a\": 1\n}\n", buf.String())
+}
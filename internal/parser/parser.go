@@ -1,66 +1,115 @@
 package parser
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"strings"
 
 	"go.yaml.in/yaml/v3"
 )
 
-// ParseDocuments decodes all YAML documents from the reader and returns
-// them as a slice of yaml.Node pointers. Each node has Kind == DocumentNode.
-func ParseDocuments(r io.Reader) ([]*yaml.Node, error) {
-	decoder := yaml.NewDecoder(r)
-	var docs []*yaml.Node
-
-	for {
-		var doc yaml.Node
-		err := decoder.Decode(&doc)
-		if err == io.EOF {
-			break
+// Format identifies the serialization format of an input or output stream.
+type Format string
+
+const (
+	// FormatYAML is one or more YAML documents separated by "---".
+	FormatYAML Format = "yaml"
+
+	// FormatJSON is one or more concatenated JSON objects.
+	FormatJSON Format = "json"
+)
+
+// DetectFormat peeks at the first non-whitespace byte of the reader without
+// consuming it. Input starting with "{" is treated as JSON, anything else
+// (including empty input) as YAML.
+func DetectFormat(r *bufio.Reader) (Format, error) {
+	for n := 1; ; n++ {
+		buf, err := r.Peek(n)
+		if len(buf) < n {
+			if err == io.EOF || err == bufio.ErrBufferFull {
+				return FormatYAML, nil
+			}
+			return "", fmt.Errorf("reading input: %w", err)
 		}
-		if err != nil {
-			return nil, fmt.Errorf("YAML parse error: %w", err)
+		switch buf[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return FormatJSON, nil
+		default:
+			return FormatYAML, nil
 		}
-		docs = append(docs, &doc)
 	}
-
-	return docs, nil
 }
 
-// UnwrapListKind checks if a document is a Kubernetes List kind and, if so,
-// unwraps its items into individual DocumentNode entries. Non-List documents
-// are returned as-is in a single-element slice.
+// UnwrapListKind checks if a document is a Kubernetes list (kind "List" or a
+// typed list such as "PodList" or "PartialObjectMetadataList") with an items
+// sequence and, if so, unwraps its items into individual DocumentNode entries.
+// Other documents are returned as-is in a single-element slice.
 func UnwrapListKind(doc *yaml.Node) []*yaml.Node {
-	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+	itemsNode, ok := listItems(doc)
+	if !ok {
 		return []*yaml.Node{doc}
 	}
 
-	root := doc.Content[0]
-	if root.Kind != yaml.MappingNode {
-		return []*yaml.Node{doc}
+	var result []*yaml.Node
+	for _, item := range itemsNode.Content {
+		result = append(result, itemDocument(item))
 	}
 
-	kind, ok := getMapValue(root, "kind")
-	if !ok || kind != "List" {
-		return []*yaml.Node{doc}
-	}
+	return result
+}
 
-	itemsNode, ok := getMapValueNode(root, "items")
-	if !ok || itemsNode.Kind != yaml.SequenceNode {
-		return []*yaml.Node{doc}
+// FilterListItems removes the items of a list document (as recognized by
+// UnwrapListKind) for which keep returns false. keep receives each item as a
+// DocumentNode, like the items returned by UnwrapListKind. It reports whether
+// doc is a list document; other documents are left unchanged.
+func FilterListItems(doc *yaml.Node, keep func(item *yaml.Node) bool) bool {
+	itemsNode, ok := listItems(doc)
+	if !ok {
+		return false
 	}
 
-	var result []*yaml.Node
+	kept := itemsNode.Content[:0]
 	for _, item := range itemsNode.Content {
-		docNode := &yaml.Node{
-			Kind:    yaml.DocumentNode,
-			Content: []*yaml.Node{item},
+		if keep(itemDocument(item)) {
+			kept = append(kept, item)
 		}
-		result = append(result, docNode)
 	}
+	itemsNode.Content = kept
+	return true
+}
 
-	return result
+// listItems returns the items sequence of a list document.
+func listItems(doc *yaml.Node) (*yaml.Node, bool) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, false
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, false
+	}
+
+	if !isListKind(MapScalar(root, "kind")) {
+		return nil, false
+	}
+
+	itemsNode := MapValue(root, "items")
+	if itemsNode == nil || itemsNode.Kind != yaml.SequenceNode {
+		return nil, false
+	}
+	return itemsNode, true
+}
+
+// itemDocument wraps a list item in a DocumentNode.
+func itemDocument(item *yaml.Node) *yaml.Node {
+	return &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{item},
+	}
 }
 
 // EncodeDocuments writes all YAML documents to the writer with kubectl-compatible
@@ -81,28 +130,235 @@ func EncodeDocuments(w io.Writer, docs []*yaml.Node) error {
 	return nil
 }
 
-// getMapValue finds a key in a MappingNode and returns its string value.
-func getMapValue(mapping *yaml.Node, key string) (string, bool) {
-	if mapping.Kind != yaml.MappingNode {
-		return "", false
+// Encoder writes documents one at a time in the given format, emitting the
+// separators needed between consecutive documents ("---" for YAML). Each call
+// writes one complete unit (a document, a List envelope part or a List item),
+// so the caller may post-process or flush the output after every call.
+type Encoder struct {
+	w      io.Writer
+	format Format
+	count  int // documents (including Lists) started so far
+	items  int // items written in the current List
+}
+
+// NewEncoder creates an Encoder writing to w.
+func NewEncoder(w io.Writer, format Format) *Encoder {
+	return &Encoder{w: w, format: format}
+}
+
+// Encode writes a single document.
+func (e *Encoder) Encode(doc *yaml.Node) error {
+	if e.format == FormatJSON {
+		return EncodeJSONDocuments(e.w, []*yaml.Node{doc})
 	}
-	for i := 0; i < len(mapping.Content)-1; i += 2 {
-		if mapping.Content[i].Value == key {
-			return mapping.Content[i+1].Value, true
+	if err := e.separate(); err != nil {
+		return err
+	}
+	doc, err := e.writeHeadComment(doc)
+	if err != nil {
+		return err
+	}
+	return EncodeDocuments(e.w, []*yaml.Node{doc})
+}
+
+// BeginList starts a List document whose items are written one at a time
+// with EncodeItem. The header holds the envelope fields that precede items;
+// its HeadComment, if any, is written above the List.
+func (e *Encoder) BeginList(header *yaml.Node) error {
+	e.items = 0
+	if e.format == FormatJSON {
+		return e.writeJSON(func(j *jsonWriter) {
+			j.headComment(header.HeadComment, 0)
+			j.write("{\n")
+			j.fields(header, 1, true)
+		})
+	}
+	if err := e.separate(); err != nil {
+		return err
+	}
+	header, err := e.writeHeadComment(header)
+	if err != nil {
+		return err
+	}
+	return e.encodeEnvelope(header)
+}
+
+// EncodeItem writes one item of the List started with BeginList, indented
+// as an element of the items sequence.
+func (e *Encoder) EncodeItem(item *yaml.Node) error {
+	first := e.items == 0
+	e.items++
+
+	if e.format == FormatJSON {
+		// The separator after an item is only known once the next item (or
+		// the end of the List) arrives, so items are written without their
+		// trailing newline.
+		return e.writeJSON(func(j *jsonWriter) {
+			if first {
+				j.write(jsonIndent + `"items": [` + "\n")
+			} else {
+				j.write(",\n")
+			}
+			if item.Kind != yaml.DocumentNode || len(item.Content) == 0 {
+				j.write(strings.Repeat(jsonIndent, 2) + "null")
+				return
+			}
+			var buf bytes.Buffer
+			ij := &jsonWriter{w: bufio.NewWriter(&buf)}
+			ij.headComment(item.HeadComment, 2)
+			ij.write(strings.Repeat(jsonIndent, 2))
+			ij.value(item.Content[0], 2, "", "")
+			if ij.err == nil {
+				ij.err = ij.w.Flush()
+			}
+			if ij.err != nil {
+				j.err = ij.err
+				return
+			}
+			j.write(strings.TrimSuffix(buf.String(), "\n"))
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeDocuments(&buf, []*yaml.Node{item}); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	if first {
+		sb.WriteString("items:\n")
+	}
+	// Every line shifts right by two columns ("- " or "  "), which keeps
+	// inline comments aligned once the item is nested in the List.
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			sb.WriteString("- " + line)
+		case line != "":
+			sb.WriteString("  " + line)
 		}
+		sb.WriteString("\n")
 	}
-	return "", false
+	_, err := io.WriteString(e.w, sb.String())
+	return err
 }
 
-// getMapValueNode finds a key in a MappingNode and returns the value Node.
-func getMapValueNode(mapping *yaml.Node, key string) (*yaml.Node, bool) {
-	if mapping.Kind != yaml.MappingNode {
-		return nil, false
+// EndList finishes the List started with BeginList. The trailer holds the
+// envelope fields that follow items.
+func (e *Encoder) EndList(trailer *yaml.Node) error {
+	empty := e.items == 0
+	e.items = 0
+
+	if e.format == FormatJSON {
+		return e.writeJSON(func(j *jsonWriter) {
+			if empty {
+				j.write(jsonIndent + `"items": []`)
+			} else {
+				j.write("\n" + jsonIndent + "]")
+			}
+			if len(trailer.Content) > 0 {
+				j.write(",\n")
+				j.fields(trailer, 1, false)
+			} else {
+				j.write("\n")
+			}
+			j.write("}\n")
+		})
+	}
+
+	if empty {
+		if _, err := io.WriteString(e.w, "items: []\n"); err != nil {
+			return err
+		}
+	}
+	return e.encodeEnvelope(trailer)
+}
+
+// writeHeadComment writes the head comment of a YAML document or List
+// header, if any, and returns node without it. The comment directly precedes
+// the content either way, where go-yaml would follow the head comment of a
+// document with a blank line.
+func (e *Encoder) writeHeadComment(node *yaml.Node) (*yaml.Node, error) {
+	if node.HeadComment == "" {
+		return node, nil
+	}
+	for _, line := range strings.Split(node.HeadComment, "\n") {
+		if _, err := io.WriteString(e.w, "# "+stripCommentMarker(line)+"\n"); err != nil {
+			return nil, fmt.Errorf("YAML encode error: %w", err)
+		}
+	}
+	bare := *node
+	bare.HeadComment = ""
+	return &bare, nil
+}
+
+// separate writes the "---" separator before every YAML document but the first.
+func (e *Encoder) separate() error {
+	e.count++
+	if e.count == 1 {
+		return nil
+	}
+	if _, err := io.WriteString(e.w, "---\n"); err != nil {
+		return fmt.Errorf("YAML encode error: %w", err)
+	}
+	return nil
+}
+
+// encodeEnvelope writes the fields of a List envelope mapping as YAML.
+func (e *Encoder) encodeEnvelope(fields *yaml.Node) error {
+	if fields == nil || len(fields.Content) == 0 {
+		return nil
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{fields}}
+	return EncodeDocuments(e.w, []*yaml.Node{doc})
+}
+
+// writeJSON runs fn against a jsonWriter for e.w and flushes the result.
+func (e *Encoder) writeJSON(fn func(j *jsonWriter)) error {
+	j := &jsonWriter{w: bufio.NewWriter(e.w)}
+	fn(j)
+	if j.err == nil {
+		j.err = j.w.Flush()
+	}
+	if j.err != nil {
+		return fmt.Errorf("JSON encode error: %w", j.err)
+	}
+	return nil
+}
+
+// MapValue returns the value of key in a MappingNode, or nil when mapping is
+// nil, is not a MappingNode or has no such key.
+func MapValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
 	}
 	for i := 0; i < len(mapping.Content)-1; i += 2 {
 		if mapping.Content[i].Value == key {
-			return mapping.Content[i+1], true
+			return mapping.Content[i+1]
 		}
 	}
-	return nil, false
+	return nil
+}
+
+// MapScalar returns the scalar value of key in a MappingNode, or "" when it
+// has no such scalar.
+func MapScalar(mapping *yaml.Node, key string) string {
+	if v := MapValue(mapping, key); v != nil && v.Kind == yaml.ScalarNode {
+		return v.Value
+	}
+	return ""
+}
+
+// CloneNode returns a deep copy of node. When comments is false, the head,
+// line and foot comments of the copy and its descendants are dropped.
+func CloneNode(node *yaml.Node, comments bool) *yaml.Node {
+	c := *node
+	if !comments {
+		c.HeadComment, c.LineComment, c.FootComment = "", "", ""
+	}
+	c.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		c.Content[i] = CloneNode(child, comments)
+	}
+	return &c
 }
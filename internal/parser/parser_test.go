@@ -11,44 +11,14 @@ import (
 	"go.yaml.in/yaml/v3"
 )
 
-// ---------------------------------------------------------------------------
-// ParseDocuments tests
-// ---------------------------------------------------------------------------
-
-func TestParseDocuments_SingleDoc(t *testing.T) {
-	input := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n"
-	docs, err := ParseDocuments(strings.NewReader(input))
-	require.NoError(t, err)
-	assert.Len(t, docs, 1)
-	assert.Equal(t, yaml.DocumentNode, docs[0].Kind)
-	assert.Equal(t, yaml.MappingNode, docs[0].Content[0].Kind)
-}
-
-func TestParseDocuments_MultiDoc(t *testing.T) {
-	data, err := os.ReadFile("../../testdata/roundtrip/multidoc.yaml")
-	require.NoError(t, err)
-
-	docs, err := ParseDocuments(bytes.NewReader(data))
-	require.NoError(t, err)
-	assert.Len(t, docs, 2)
-
-	// Verify both are documents with mapping roots.
-	for i, doc := range docs {
-		assert.Equal(t, yaml.DocumentNode, doc.Kind, "doc %d should be DocumentNode", i)
-		assert.Equal(t, yaml.MappingNode, doc.Content[0].Kind, "doc %d root should be MappingNode", i)
-	}
-}
-
-func TestParseDocuments_EmptyInput(t *testing.T) {
-	docs, err := ParseDocuments(strings.NewReader(""))
-	require.NoError(t, err)
-	assert.Len(t, docs, 0)
-}
-
-func TestParseDocuments_InvalidYAML(t *testing.T) {
-	_, err := ParseDocuments(strings.NewReader(":\n  - :\n    -"))
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "YAML parse error")
+// parseDocument parses a single YAML document without a Decoder, so Lists
+// are returned whole.
+func parseDocument(t *testing.T, input string) *yaml.Node {
+	t.Helper()
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(input), &doc))
+	require.Equal(t, yaml.DocumentNode, doc.Kind)
+	return &doc
 }
 
 // ---------------------------------------------------------------------------
@@ -56,26 +26,19 @@ func TestParseDocuments_InvalidYAML(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestUnwrapListKind_NotAList(t *testing.T) {
-	input := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n"
-	docs, err := ParseDocuments(strings.NewReader(input))
-	require.NoError(t, err)
-	require.Len(t, docs, 1)
+	doc := parseDocument(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n")
 
-	result := UnwrapListKind(docs[0])
+	result := UnwrapListKind(doc)
 	assert.Len(t, result, 1)
 	// Should be the same document object (pointer equality).
-	assert.Same(t, docs[0], result[0])
+	assert.Same(t, doc, result[0])
 }
 
 func TestUnwrapListKind_ListWithItems(t *testing.T) {
 	data, err := os.ReadFile("../../testdata/roundtrip/list_kind.yaml")
 	require.NoError(t, err)
 
-	docs, err := ParseDocuments(bytes.NewReader(data))
-	require.NoError(t, err)
-	require.Len(t, docs, 1)
-
-	unwrapped := UnwrapListKind(docs[0])
+	unwrapped := UnwrapListKind(parseDocument(t, string(data)))
 	assert.Len(t, unwrapped, 2)
 
 	// Each unwrapped item should be a DocumentNode wrapping a MappingNode.
@@ -86,16 +49,12 @@ func TestUnwrapListKind_ListWithItems(t *testing.T) {
 	}
 
 	// Verify first item is cm-one.
-	name1, ok := getMapValue(unwrapped[0].Content[0], "kind")
-	require.True(t, ok)
-	assert.Equal(t, "ConfigMap", name1)
+	assert.Equal(t, "ConfigMap", MapScalar(unwrapped[0].Content[0], "kind"))
 
 	// Verify second item is cm-two by checking metadata.name.
-	metaNode, ok := getMapValueNode(unwrapped[1].Content[0], "metadata")
-	require.True(t, ok)
-	nameVal, ok := getMapValue(metaNode, "name")
-	require.True(t, ok)
-	assert.Equal(t, "cm-two", nameVal)
+	metaNode := MapValue(unwrapped[1].Content[0], "metadata")
+	require.NotNil(t, metaNode)
+	assert.Equal(t, "cm-two", MapScalar(metaNode, "name"))
 }
 
 // ---------------------------------------------------------------------------
@@ -108,8 +67,7 @@ func roundTripTest(t *testing.T, fixturePath string) {
 	data, err := os.ReadFile(fixturePath)
 	require.NoError(t, err, "failed to read fixture: %s", fixturePath)
 
-	docs, err := ParseDocuments(bytes.NewReader(data))
-	require.NoError(t, err, "ParseDocuments failed for %s", fixturePath)
+	docs, _ := decodeAll(t, string(data))
 
 	var buf bytes.Buffer
 	err = EncodeDocuments(&buf, docs)
@@ -150,14 +108,7 @@ func TestRoundTrip_ListKind(t *testing.T) {
 	data, err := os.ReadFile("../../testdata/roundtrip/list_kind.yaml")
 	require.NoError(t, err)
 
-	docs, err := ParseDocuments(bytes.NewReader(data))
-	require.NoError(t, err)
-	require.Len(t, docs, 1)
-
-	var allDocs []*yaml.Node
-	for _, doc := range docs {
-		allDocs = append(allDocs, UnwrapListKind(doc)...)
-	}
+	allDocs := UnwrapListKind(parseDocument(t, string(data)))
 	assert.Len(t, allDocs, 2)
 
 	var buf bytes.Buffer
@@ -178,3 +129,60 @@ func TestRoundTrip_ListKind(t *testing.T) {
 	// Verify second document contains cm-two.
 	assert.Contains(t, docParts[1], "name: cm-two")
 }
+
+func TestUnwrapListKind_TypedLists(t *testing.T) {
+	for _, kind := range []string{"PodList", "DeploymentList", "PartialObjectMetadataList"} {
+		t.Run(kind, func(t *testing.T) {
+			input := "kind: " + kind + "\nitems: [{metadata: {name: one}}, {metadata: {name: two}}]\n"
+			unwrapped := UnwrapListKind(parseDocument(t, input))
+			assert.Len(t, unwrapped, 2)
+		})
+	}
+}
+
+func TestUnwrapListKind_ListSuffixWithoutItems(t *testing.T) {
+	// A kind ending in "List" without an items sequence is not unwrapped.
+	doc := parseDocument(t, "kind: AllowList\nspec:\n  entries: []\n")
+
+	result := UnwrapListKind(doc)
+	assert.Len(t, result, 1)
+	assert.Same(t, doc, result[0])
+}
+
+func TestFilterListItems(t *testing.T) {
+	doc := parseDocument(t, "kind: List\nitems: [{metadata: {name: one}}, {metadata: {name: two}}, {metadata: {name: three}}]\n")
+
+	isList := FilterListItems(doc, func(item *yaml.Node) bool {
+		return MapScalar(MapValue(item.Content[0], "metadata"), "name") != "two"
+	})
+	assert.True(t, isList)
+
+	var names []string
+	for _, item := range UnwrapListKind(doc) {
+		names = append(names, MapScalar(MapValue(item.Content[0], "metadata"), "name"))
+	}
+	assert.Equal(t, []string{"one", "three"}, names)
+}
+
+func TestFilterListItems_NotAList(t *testing.T) {
+	doc := parseDocument(t, "kind: ConfigMap\nmetadata:\n  name: test\n")
+
+	called := false
+	isList := FilterListItems(doc, func(*yaml.Node) bool { called = true; return false })
+	assert.False(t, isList)
+	assert.False(t, called)
+}
+
+func TestCloneNode(t *testing.T) {
+	doc := parseDocument(t, "# head\ndata:\n  key: value # line\n")
+
+	clone := CloneNode(doc, true)
+	MapValue(clone.Content[0], "data").Content[1].Value = "changed"
+	assert.Equal(t, "value", MapScalar(MapValue(doc.Content[0], "data"), "key"))
+	assert.Equal(t, "# line", MapValue(clone.Content[0], "data").Content[1].LineComment)
+
+	bare := CloneNode(doc, false)
+	var buf bytes.Buffer
+	require.NoError(t, EncodeDocuments(&buf, []*yaml.Node{bare}))
+	assert.Equal(t, "data:\n  key: value\n", buf.String())
+}
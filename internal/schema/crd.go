@@ -0,0 +1,88 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// crd is the part of a CustomResourceDefinition (apiextensions.k8s.io/v1)
+// that describes the schemas of its custom resources.
+type crd struct {
+	Kind  string `json:"kind"`
+	Items []crd  `json:"items"` // when the document is a List of CRDs
+	Spec  struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Versions []struct {
+			Name   string `json:"name"`
+			Schema struct {
+				OpenAPIV3Schema *Schema `json:"openAPIV3Schema"`
+			} `json:"schema"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// LoadCRDs adds the openAPIV3Schema of every version of the
+// CustomResourceDefinitions in data, which holds YAML or JSON documents (or
+// a List of CRDs), as the schemas of their custom resources. Documents that
+// are not CRDs are ignored. It returns the number of schemas added.
+func (r *Registry) LoadCRDs(data []byte) (int, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	loaded := 0
+	for i := 0; ; i++ {
+		var doc any
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return loaded, fmt.Errorf("parsing document %d: %w", i, err)
+		}
+		if doc == nil {
+			continue
+		}
+		// Round-trip through JSON to decode into Schema with its JSON
+		// field names and extension handling.
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			return loaded, fmt.Errorf("parsing document %d: %w", i, err)
+		}
+		var c crd
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return loaded, fmt.Errorf("parsing document %d: %w", i, err)
+		}
+		items := []crd{c}
+		if c.Kind == "List" || c.Kind == "CustomResourceDefinitionList" {
+			items = c.Items
+		}
+		for _, item := range items {
+			if item.Kind != "CustomResourceDefinition" {
+				continue
+			}
+			loaded += r.addCRD(item)
+		}
+	}
+	return loaded, nil
+}
+
+// addCRD registers the schemas of the versions of c and returns their
+// number.
+func (r *Registry) addCRD(c crd) int {
+	n := 0
+	for _, v := range c.Spec.Versions {
+		if v.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		apiVersion := c.Spec.Group + "/" + v.Name
+		r.addKind(apiVersion, c.Spec.Names.Kind, v.Schema.OpenAPIV3Schema)
+		n++
+	}
+	return n
+}
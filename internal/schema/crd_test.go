@@ -0,0 +1,130 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rolloutCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: rollouts.argoproj.io
+spec:
+  group: argoproj.io
+  names:
+    kind: Rollout
+  versions:
+  - name: v1alpha1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              selector:
+                type: object
+                x-kubernetes-map-type: atomic
+              strategy:
+                type: object
+                properties:
+                  canary:
+                    type: object
+                    properties:
+                      steps:
+                        type: array
+                        x-kubernetes-list-type: atomic
+                        items:
+                          type: object
+              ports:
+                type: array
+                x-kubernetes-list-type: map
+                x-kubernetes-list-map-keys: [port, protocol]
+                items:
+                  type: object
+                  properties:
+                    port:
+                      type: integer
+                    protocol:
+                      type: string
+                      default: TCP
+              extra:
+                type: object
+                additionalProperties: true
+`
+
+func TestLoadCRDs(t *testing.T) {
+	r := NewRegistry()
+	n, err := r.LoadCRDs([]byte(rolloutCRD))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	rollout := r.ForKind("argoproj.io/v1alpha1", "Rollout")
+	require.NotNil(t, rollout)
+	spec := rollout.Field("spec")
+	assert.Equal(t, "map-type: atomic", spec.Field("selector").Semantics())
+	assert.Equal(t, "list-type: atomic", spec.Field("strategy").Field("canary").Field("steps").Semantics())
+	assert.Equal(t, "list-type: map keys=[port,protocol]", spec.Field("ports").Semantics())
+	assert.Equal(t, map[string]any{"protocol": "TCP"}, spec.Field("ports").KeyDefaults())
+	assert.Nil(t, spec.Field("extra").Field("anything"))
+	assert.Nil(t, r.ForKind("argoproj.io/v1", "Rollout"))
+}
+
+func TestLoadCRDs_MultipleDocumentsAndLists(t *testing.T) {
+	data := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-a-crd
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: apiextensions.k8s.io/v1
+  kind: CustomResourceDefinition
+  spec:
+    group: cert-manager.io
+    names:
+      kind: Certificate
+    versions:
+    - name: v1
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                dnsNames:
+                  type: array
+                  items:
+                    type: string
+                  x-kubernetes-list-type: set
+    - name: v1beta1
+      served: false
+---
+{"apiVersion": "apiextensions.k8s.io/v1", "kind": "CustomResourceDefinition",
+ "spec": {"group": "example.com", "names": {"kind": "Widget"},
+  "versions": [{"name": "v1", "schema": {"openAPIV3Schema": {"type": "object"}}}]}}
+`
+	r := NewRegistry()
+	n, err := r.LoadCRDs([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	cert := r.ForKind("cert-manager.io/v1", "Certificate")
+	require.NotNil(t, cert)
+	assert.Equal(t, "list-type: set", cert.Field("spec").Field("dnsNames").Semantics())
+	assert.NotNil(t, r.ForKind("example.com/v1", "Widget"))
+}
+
+func TestLoadCRDs_Errors(t *testing.T) {
+	r := NewRegistry()
+	n, err := r.LoadCRDs([]byte("kind: ConfigMap\n"))
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+
+	_, err = r.LoadCRDs([]byte("kind: [unclosed\n"))
+	assert.ErrorContains(t, err, "parsing document 0")
+}
@@ -0,0 +1,292 @@
+// Package schema reads the OpenAPI v3 schemas of Kubernetes types, reduced to
+// what is needed to follow an object's fields and know their server-side
+// apply semantics: list types, list-map keys and map types.
+package schema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// builtinSchemas is a subset of the Kubernetes OpenAPI v3 schemas for
+// built-in types, so the semantics of common objects are known offline.
+//
+//go:embed builtin.json
+var builtinSchemas []byte
+
+// Schema is an OpenAPI v3 schema of a Kubernetes type or field.
+type Schema struct {
+	Type       string             `json:"type"`
+	Properties map[string]*Schema `json:"properties"`
+	Items      *Schema            `json:"items"`
+	Default    any                `json:"default"`
+
+	// AdditionalProperties is the schema of the values of a map. It is nil
+	// when additionalProperties is absent or a boolean.
+	AdditionalProperties *Schema `json:"-"`
+
+	// ListType is the x-kubernetes-list-type of an array: "atomic", "set"
+	// or "map". ListMapKeys are the fields identifying the items of a
+	// "map" list.
+	ListType    string   `json:"x-kubernetes-list-type"`
+	ListMapKeys []string `json:"x-kubernetes-list-map-keys"`
+
+	// MapType is the x-kubernetes-map-type of an object: "atomic" or
+	// "granular".
+	MapType string `json:"x-kubernetes-map-type"`
+
+	GroupVersionKinds []GroupVersionKind `json:"x-kubernetes-group-version-kind"`
+
+	Ref   string    `json:"$ref"`
+	AllOf []*Schema `json:"allOf"`
+
+	registry *Registry // resolves Ref
+}
+
+// GroupVersionKind identifies the kind a top-level schema describes.
+type GroupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// UnmarshalJSON decodes a schema, accepting a boolean additionalProperties.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	var extra struct {
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return err
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(extra.AdditionalProperties)), "{") {
+		return json.Unmarshal(extra.AdditionalProperties, &s.AdditionalProperties)
+	}
+	return nil
+}
+
+// resolved returns the schema s refers to, either directly through $ref or
+// through a single-element allOf (which is how OpenAPI v3 documents refer to
+// a type from a property that also has a description or default). It returns
+// s itself when s is not a reference or the target is unknown.
+func (s *Schema) resolved() *Schema {
+	for range 8 { // references to references are rare; bound the chain
+		ref := s.Ref
+		if ref == "" && len(s.AllOf) == 1 {
+			ref = s.AllOf[0].Ref
+		}
+		target := s.registry.lookup(ref)
+		if target == nil {
+			return s
+		}
+		s = target
+	}
+	return s
+}
+
+// Field returns the schema of the named field of an object, or of the values
+// of a map. It returns nil when the field is not described, and it is safe to
+// call on a nil Schema.
+func (s *Schema) Field(name string) *Schema {
+	if s == nil {
+		return nil
+	}
+	t := s.resolved()
+	if f, ok := t.Properties[name]; ok {
+		return f
+	}
+	return t.AdditionalProperties
+}
+
+// Item returns the schema of the items of an array, or nil. It is safe to
+// call on a nil Schema.
+func (s *Schema) Item() *Schema {
+	if s == nil {
+		return nil
+	}
+	if s.Items != nil {
+		return s.Items
+	}
+	return s.resolved().Items
+}
+
+// List returns the x-kubernetes-list-type of s or of the type it refers to,
+// and its list-map keys. It returns "" for a nil Schema.
+func (s *Schema) List() (listType string, keys []string) {
+	if s == nil {
+		return "", nil
+	}
+	if s.ListType != "" {
+		return s.ListType, s.ListMapKeys
+	}
+	t := s.resolved()
+	return t.ListType, t.ListMapKeys
+}
+
+// mapType returns the x-kubernetes-map-type of s or of the type it refers to.
+func (s *Schema) mapType() string {
+	if s.MapType != "" {
+		return s.MapType
+	}
+	return s.resolved().MapType
+}
+
+// Semantics describes how server-side apply merges the field, for example
+// "list-type: map keys=[containerPort,protocol]", "list-type: set" or
+// "map-type: atomic". It returns "" for fields merged field by field, which
+// is the default for objects and maps, and for a nil Schema.
+func (s *Schema) Semantics() string {
+	if s == nil {
+		return ""
+	}
+	switch listType, keys := s.List(); listType {
+	case "map":
+		return fmt.Sprintf("list-type: map keys=[%s]", strings.Join(keys, ","))
+	case "":
+	default:
+		return "list-type: " + listType
+	}
+	if s.mapType() == "atomic" {
+		return "map-type: atomic"
+	}
+	return ""
+}
+
+// KeyDefaults returns the default values of the list-map keys of the items of
+// an array (such as "TCP" for the protocol of container ports), which objects
+// may omit although the server includes them in the keys of managedFields. It
+// returns nil when no key has a default.
+func (s *Schema) KeyDefaults() map[string]any {
+	if s == nil {
+		return nil
+	}
+	listType, keys := s.List()
+	if listType != "map" {
+		return nil
+	}
+	var defaults map[string]any
+	item := s.Item()
+	for _, key := range keys {
+		f := item.Field(key)
+		if f == nil || f.Default == nil {
+			continue
+		}
+		if defaults == nil {
+			defaults = make(map[string]any)
+		}
+		defaults[key] = f.Default
+	}
+	return defaults
+}
+
+// Registry holds named schemas and the kinds they describe.
+type Registry struct {
+	schemas map[string]*Schema
+	kinds   map[GroupVersionKind]*Schema
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		schemas: make(map[string]*Schema),
+		kinds:   make(map[GroupVersionKind]*Schema),
+	}
+}
+
+// Builtin returns a Registry holding the bundled schemas of built-in types.
+func Builtin() *Registry {
+	r := NewRegistry()
+	if err := r.LoadOpenAPI(builtinSchemas); err != nil {
+		panic(fmt.Sprintf("loading built-in schemas: %v", err))
+	}
+	return r
+}
+
+// LoadOpenAPI adds the schemas of an OpenAPI v3 document in JSON, such as the
+// output of "kubectl get --raw /openapi/v3/apis/apps/v1", to the registry.
+// Schemas replace previously loaded ones of the same name or kind.
+func (r *Registry) LoadOpenAPI(data []byte) error {
+	var doc struct {
+		Components struct {
+			Schemas map[string]*Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing OpenAPI document: %w", err)
+	}
+	if len(doc.Components.Schemas) == 0 {
+		return fmt.Errorf("parsing OpenAPI document: no components.schemas")
+	}
+	for name, s := range doc.Components.Schemas {
+		r.add(name, s)
+	}
+	return nil
+}
+
+// add registers a named schema, and the kinds it is declared for through
+// x-kubernetes-group-version-kind. References in s are resolved against the
+// registry when s is used.
+func (r *Registry) add(name string, s *Schema) {
+	s.bind(r)
+	r.schemas[name] = s
+	for _, gvk := range s.GroupVersionKinds {
+		r.kinds[gvk] = s
+	}
+}
+
+// addKind registers s as the schema of the kind identified by apiVersion
+// (such as "apps/v1") and kind.
+func (r *Registry) addKind(apiVersion, kind string, s *Schema) {
+	s.bind(r)
+	r.kinds[parseGVK(apiVersion, kind)] = s
+}
+
+// ForKind returns the schema of objects of the given apiVersion and kind, or
+// nil if it is not known.
+func (r *Registry) ForKind(apiVersion, kind string) *Schema {
+	if r == nil {
+		return nil
+	}
+	return r.kinds[parseGVK(apiVersion, kind)]
+}
+
+// lookup returns the schema a $ref such as
+// "#/components/schemas/io.k8s.api.core.v1.PodSpec" points to, or nil.
+func (r *Registry) lookup(ref string) *Schema {
+	if r == nil || ref == "" {
+		return nil
+	}
+	i := strings.LastIndexByte(ref, '/')
+	return r.schemas[ref[i+1:]]
+}
+
+// bind sets the registry resolving the references of s and its descendants.
+func (s *Schema) bind(r *Registry) {
+	if s == nil || s.registry == r {
+		return
+	}
+	s.registry = r
+	for _, p := range s.Properties {
+		p.bind(r)
+	}
+	for _, a := range s.AllOf {
+		a.bind(r)
+	}
+	s.Items.bind(r)
+	s.AdditionalProperties.bind(r)
+}
+
+// parseGVK splits an apiVersion such as "apps/v1" or "v1" into group and
+// version.
+func parseGVK(apiVersion, kind string) GroupVersionKind {
+	group, version, ok := strings.Cut(apiVersion, "/")
+	if !ok {
+		group, version = "", apiVersion
+	}
+	return GroupVersionKind{Group: group, Version: version, Kind: kind}
+}
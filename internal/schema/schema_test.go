@@ -0,0 +1,116 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltin_Semantics(t *testing.T) {
+	r := Builtin()
+	deploy := r.ForKind("apps/v1", "Deployment")
+	require.NotNil(t, deploy)
+
+	podSpec := deploy.Field("spec").Field("template").Field("spec")
+	container := podSpec.Field("containers").Item()
+
+	tests := []struct {
+		name   string
+		schema *Schema
+		want   string
+	}{
+		{"containers", podSpec.Field("containers"), "list-type: map keys=[name]"},
+		{"ports", container.Field("ports"), "list-type: map keys=[containerPort,protocol]"},
+		{"args", container.Field("args"), "list-type: atomic"},
+		{"tolerations", podSpec.Field("tolerations"), "list-type: atomic"},
+		{"nodeSelector", podSpec.Field("nodeSelector"), "map-type: atomic"},
+		{"selector (through $ref)", deploy.Field("spec").Field("selector"), "map-type: atomic"},
+		{"finalizers", deploy.Field("metadata").Field("finalizers"), "list-type: set"},
+		{"labels", deploy.Field("metadata").Field("labels"), ""},
+		{"label value", deploy.Field("metadata").Field("labels").Field("app"), ""},
+		{"image (not described)", container.Field("image"), ""},
+		{"nil", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.schema.Semantics())
+		})
+	}
+}
+
+func TestBuiltin_CoreKinds(t *testing.T) {
+	r := Builtin()
+	for _, k := range []struct{ apiVersion, kind string }{
+		{"v1", "Pod"},
+		{"v1", "Service"},
+		{"v1", "ConfigMap"},
+		{"apps/v1", "StatefulSet"},
+		{"batch/v1", "CronJob"},
+		{"networking.k8s.io/v1", "Ingress"},
+	} {
+		assert.NotNil(t, r.ForKind(k.apiVersion, k.kind), "%s %s", k.apiVersion, k.kind)
+	}
+	assert.Nil(t, r.ForKind("v1", "Deployment"))
+	assert.Nil(t, r.ForKind("example.com/v1", "Widget"))
+
+	var nilRegistry *Registry
+	assert.Nil(t, nilRegistry.ForKind("v1", "Pod"))
+}
+
+func TestKeyDefaults(t *testing.T) {
+	r := Builtin()
+	pod := r.ForKind("v1", "Pod")
+	container := pod.Field("spec").Field("containers").Item()
+
+	assert.Equal(t, map[string]any{"protocol": "TCP"}, container.Field("ports").KeyDefaults())
+	assert.Equal(t, map[string]any{"protocol": "TCP"},
+		r.ForKind("v1", "Service").Field("spec").Field("ports").KeyDefaults())
+	assert.Nil(t, pod.Field("spec").Field("containers").KeyDefaults())
+	assert.Nil(t, container.Field("args").KeyDefaults())
+	assert.Nil(t, (*Schema)(nil).KeyDefaults())
+}
+
+func TestLoadOpenAPI(t *testing.T) {
+	doc := `{
+  "components": {
+    "schemas": {
+      "com.example.v1.Widget": {
+        "type": "object",
+        "properties": {
+          "spec": {"allOf": [{"$ref": "#/components/schemas/com.example.v1.WidgetSpec"}]}
+        },
+        "x-kubernetes-group-version-kind": [{"group": "example.com", "version": "v1", "kind": "Widget"}]
+      },
+      "com.example.v1.WidgetSpec": {
+        "type": "object",
+        "properties": {
+          "parts": {
+            "type": "array",
+            "items": {"type": "object", "properties": {"id": {"type": "string", "default": "main"}}},
+            "x-kubernetes-list-type": "map",
+            "x-kubernetes-list-map-keys": ["id"]
+          },
+          "labels": {"type": "object", "additionalProperties": true, "x-kubernetes-map-type": "atomic"}
+        }
+      }
+    }
+  }
+}`
+	r := NewRegistry()
+	require.NoError(t, r.LoadOpenAPI([]byte(doc)))
+
+	widget := r.ForKind("example.com/v1", "Widget")
+	require.NotNil(t, widget)
+	parts := widget.Field("spec").Field("parts")
+	assert.Equal(t, "list-type: map keys=[id]", parts.Semantics())
+	assert.Equal(t, map[string]any{"id": "main"}, parts.KeyDefaults())
+	assert.Equal(t, "map-type: atomic", widget.Field("spec").Field("labels").Semantics())
+	assert.Nil(t, widget.Field("spec").Field("labels").Field("x"))
+}
+
+func TestLoadOpenAPI_Errors(t *testing.T) {
+	r := NewRegistry()
+	assert.ErrorContains(t, r.LoadOpenAPI([]byte("not json")), "parsing OpenAPI document")
+	assert.ErrorContains(t, r.LoadOpenAPI([]byte(`{"openapi": "3.0.0"}`)), "no components.schemas")
+}
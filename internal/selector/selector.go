@@ -0,0 +1,76 @@
+// Package selector filters Kubernetes objects by namespace, kind and name.
+package selector
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"go.yaml.in/yaml/v3"
+)
+
+// Selector selects objects by namespace, kind and name. Each field lists
+// glob patterns (as in path.Match); an object matches a field when it
+// matches any of its patterns, and matches the Selector when it matches
+// every non-empty field. Kinds are compared case-insensitively.
+type Selector struct {
+	Namespaces []string
+	Kinds      []string
+	Names      []string
+}
+
+// Empty reports whether the Selector matches every object.
+func (s Selector) Empty() bool {
+	return len(s.Namespaces) == 0 && len(s.Kinds) == 0 && len(s.Names) == 0
+}
+
+// Validate reports the first malformed pattern.
+func (s Selector) Validate() error {
+	for _, patterns := range [][]string{s.Namespaces, s.Kinds, s.Names} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the resource root MappingNode is selected.
+// defaultKind is the kind of objects without one, such as the items of typed
+// lists. Cluster-scoped objects (without a namespace) never match a namespace
+// pattern.
+func (s Selector) Matches(root *yaml.Node, defaultKind string) bool {
+	metadata := parser.MapValue(root, "metadata")
+	kind := parser.MapScalar(root, "kind")
+	if kind == "" {
+		kind = defaultKind
+	}
+	return matchAny(s.Namespaces, parser.MapScalar(metadata, "namespace"), false) &&
+		matchAny(s.Kinds, kind, true) &&
+		matchAny(s.Names, parser.MapScalar(metadata, "name"), false)
+}
+
+// matchAny reports whether value matches one of patterns, or patterns is
+// empty.
+func matchAny(patterns []string, value string, foldCase bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	if value == "" {
+		return false
+	}
+	if foldCase {
+		value = strings.ToLower(value)
+	}
+	for _, p := range patterns {
+		if foldCase {
+			p = strings.ToLower(p)
+		}
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
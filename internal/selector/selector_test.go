@@ -0,0 +1,66 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v3"
+)
+
+func parseRoot(t *testing.T, src string) *yaml.Node {
+	t.Helper()
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(src), &doc))
+	return doc.Content[0]
+}
+
+func TestSelector_Matches(t *testing.T) {
+	deploy := parseRoot(t, `
+kind: Deployment
+metadata:
+  name: web-frontend
+  namespace: prod
+`)
+	node := parseRoot(t, `
+kind: Node
+metadata:
+  name: worker-1
+`)
+
+	tests := []struct {
+		name         string
+		sel          Selector
+		deploy, node bool
+	}{
+		{"empty", Selector{}, true, true},
+		{"namespace", Selector{Namespaces: []string{"prod"}}, true, false},
+		{"namespace glob", Selector{Namespaces: []string{"dev", "pr*"}}, true, false},
+		{"kind case-insensitive", Selector{Kinds: []string{"deployment"}}, true, false},
+		{"kinds", Selector{Kinds: []string{"Deployment", "NODE"}}, true, true},
+		{"name glob", Selector{Names: []string{"web-*"}}, true, false},
+		{"all fields", Selector{Namespaces: []string{"prod"}, Kinds: []string{"Deployment"}, Names: []string{"web-frontend"}}, true, false},
+		{"one field mismatch", Selector{Namespaces: []string{"prod"}, Names: []string{"api"}}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.deploy, tt.sel.Matches(deploy, ""), "deployment")
+			assert.Equal(t, tt.node, tt.sel.Matches(node, ""), "node")
+		})
+	}
+}
+
+func TestSelector_MatchesDefaultKind(t *testing.T) {
+	// Items of typed lists have no kind of their own.
+	item := parseRoot(t, "metadata:\n  name: web-0\n  namespace: default\n")
+	sel := Selector{Kinds: []string{"pod"}}
+
+	assert.True(t, sel.Matches(item, "Pod"))
+	assert.False(t, sel.Matches(item, "Service"))
+	assert.False(t, sel.Matches(item, ""))
+}
+
+func TestSelector_Validate(t *testing.T) {
+	assert.NoError(t, Selector{Names: []string{"web-*"}}.Validate())
+	assert.Error(t, Selector{Kinds: []string{"[Deploy"}}.Validate())
+}
@@ -0,0 +1,51 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"2h", 2 * time.Hour},
+		{"90s", 90 * time.Second},
+		{"3d", 72 * time.Hour},
+		{"1w2d", 9 * 24 * time.Hour},
+		{"1d12h30m", 36*time.Hour + 30*time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDuration(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseDuration_Invalid(t *testing.T) {
+	for _, in := range []string{"", "d", "xd", "2", "-1h", "1h2d", "2 hours"} {
+		_, err := ParseDuration(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	got, err := ParseTime("2h", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-2*time.Hour), got)
+
+	got, err = ParseTime("2024-06-14T08:30:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 6, 14, 8, 30, 0, 0, time.UTC), got)
+
+	_, err = ParseTime("yesterday", now)
+	assert.EqualError(t, err, `invalid time "yesterday": want a duration (such as 2h or 3d) or an RFC3339 time`)
+}
@@ -0,0 +1,84 @@
+// Package watch supports annotating the endless object streams printed by
+// "kubectl get -w", optionally wrapped in WatchEvent envelopes
+// (--output-watch-events), and tracks ownership across revisions of the same
+// object.
+package watch
+
+import (
+	"github.com/ahmetb/kubectl-fields/internal/annotate"
+	"github.com/ahmetb/kubectl-fields/internal/parser"
+	"go.yaml.in/yaml/v3"
+)
+
+// Deleted is the WatchEvent type reported when an object is deleted.
+const Deleted = "DELETED"
+
+// UnwrapEvent returns the event type and the wrapped object of a WatchEvent
+// document ({type, object}). The object is returned as a DocumentNode sharing
+// nodes with the event, so annotating it also annotates the event in place.
+// ok is false when doc is not a WatchEvent.
+func UnwrapEvent(doc *yaml.Node) (eventType string, object *yaml.Node, ok bool) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return "", nil, false
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode || len(root.Content) != 4 {
+		return "", nil, false
+	}
+
+	var typeNode, objectNode *yaml.Node
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		switch root.Content[i].Value {
+		case "type":
+			typeNode = root.Content[i+1]
+		case "object":
+			objectNode = root.Content[i+1]
+		}
+	}
+	if typeNode == nil || typeNode.Kind != yaml.ScalarNode || objectNode == nil || objectNode.Kind != yaml.MappingNode {
+		return "", nil, false
+	}
+	return typeNode.Value, &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{objectNode}}, true
+}
+
+// UID returns metadata.uid of a resource root MappingNode, or "" if absent.
+func UID(root *yaml.Node) string {
+	return parser.MapScalar(parser.MapValue(root, "metadata"), "uid")
+}
+
+// Tracker remembers the field ownership of the latest revision of each
+// object, keyed by UID, so that the next revision can be compared with it.
+type Tracker struct {
+	revisions map[string]annotate.Ownership
+}
+
+// NewTracker creates an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{revisions: make(map[string]annotate.Ownership)}
+}
+
+// Previous returns the ownership recorded for the previous revision of the
+// object with the given UID, or nil if it has not been seen (or has no UID).
+func (t *Tracker) Previous(uid string) annotate.Ownership {
+	if uid == "" {
+		return nil
+	}
+	return t.revisions[uid]
+}
+
+// Record stores the ownership of the latest revision of the object with the
+// given UID. Deleted objects are forgotten, so a later object reusing the UID
+// (which Kubernetes does not do) starts afresh.
+func (t *Tracker) Record(uid, eventType string, owned annotate.Ownership) {
+	if uid == "" {
+		return
+	}
+	if eventType == Deleted {
+		delete(t.revisions, uid)
+		return
+	}
+	if owned == nil {
+		owned = annotate.Ownership{}
+	}
+	t.revisions[uid] = owned
+}
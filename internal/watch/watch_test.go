@@ -0,0 +1,69 @@
+package watch
+
+import (
+	"testing"
+
+	"github.com/ahmetb/kubectl-fields/internal/annotate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v3"
+)
+
+func parseDoc(t *testing.T, input string) *yaml.Node {
+	t.Helper()
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(input), &doc))
+	return &doc
+}
+
+func TestUnwrapEvent(t *testing.T) {
+	doc := parseDoc(t, "type: MODIFIED\nobject:\n  kind: Pod\n  metadata:\n    uid: abc\n")
+	eventType, object, ok := UnwrapEvent(doc)
+	require.True(t, ok)
+	assert.Equal(t, "MODIFIED", eventType)
+	require.Equal(t, yaml.DocumentNode, object.Kind)
+	assert.Equal(t, "abc", UID(object.Content[0]))
+
+	// The object shares nodes with the event, so edits show up in place.
+	object.Content[0].Content[0].LineComment = "edited"
+	assert.Equal(t, "edited", doc.Content[0].Content[3].Content[0].LineComment)
+}
+
+func TestUnwrapEvent_NotAnEvent(t *testing.T) {
+	for _, input := range []string{
+		"kind: Pod\nmetadata:\n  uid: abc\n",
+		"type: ADDED\nobject: scalar\n",
+		"type: ADDED\nobject: {}\nextra: true\n",
+		"- type: ADDED\n",
+	} {
+		_, _, ok := UnwrapEvent(parseDoc(t, input))
+		assert.False(t, ok, input)
+	}
+}
+
+func TestUID_Missing(t *testing.T) {
+	assert.Equal(t, "", UID(parseDoc(t, "kind: Pod\n").Content[0]))
+	assert.Equal(t, "", UID(parseDoc(t, "metadata: []\n").Content[0]))
+}
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+	assert.Nil(t, tr.Previous("abc"))
+
+	owned := annotate.Ownership{".spec.replicas": {Manager: "kubectl"}}
+	tr.Record("abc", "ADDED", owned)
+	assert.Equal(t, owned, tr.Previous("abc"))
+
+	// Revisions without managedFields are still recorded, so every field of
+	// the next revision counts as changed.
+	tr.Record("abc", "MODIFIED", nil)
+	assert.NotNil(t, tr.Previous("abc"))
+	assert.Empty(t, tr.Previous("abc"))
+
+	tr.Record("abc", Deleted, owned)
+	assert.Nil(t, tr.Previous("abc"))
+
+	// Objects without a UID are never tracked.
+	tr.Record("", "ADDED", owned)
+	assert.Nil(t, tr.Previous(""))
+}